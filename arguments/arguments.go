@@ -5,96 +5,396 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
+// commands are the actions we can run, checkout is used when none is given
+var commands = []struct {
+	name        string
+	description string
+}{
+	{"checkout", "Clone the repository and merge the pull request (default)"},
+	{"teardown", "Commit, tag and push the build's changes from --target-dir"},
+	{"verify", "Check that an existing checkout in --target-dir is at --sha"},
+	{"doctor", "Check that Git and the repository are usable from here"},
+	{"version", "Display the version number"},
+}
+
 // CommandArgs is the complete list of arguments we would get back
 type CommandArgs struct {
-	ScmURL        string
-	Host          string
-	Repo          string
-	CloneURL      string
-	Branch        string
-	SHA           string
-	PullRequest   int
-	CloneMethod   string
-	TargetDir     string
-	GitName       string
-	GitEmail      string
-	HTTPSUsername string
-	HTTPSToken    string
-	Version       bool
+	Command          string
+	ScmURL           string
+	Host             string
+	Repo             string
+	CloneURL         string
+	Branch           string
+	SHA              string
+	BeforeSHA        string
+	PullRequest      int
+	CloneMethod      string
+	TargetDir        string
+	Manifest         string
+	ManifestRepos    []ManifestRepo
+	GitName          string
+	GitEmail         string
+	HTTPSUsername    string
+	HTTPSToken       string
+	ResultFile       string
+	MetaFile         string
+	EnvFile          string
+	MetricsFile      string
+	ChangedFiles     string
+	ChangedFilesJSON string
+	OnlyIfChanged    []string
+	SkipCI           bool
+	SkipCIMarkers    []string
+	TraceEndpoint    string
+	TraceFile        string
+	CommitMessage    string
+	Tag              string
+	TagMessage       string
+	AllowPRPush      bool
+	VerifyBranch     bool
+	IsolateGit       bool
+	Backend          string
+	Progress         string
+	MinGitVersion    string
+	KeepOnFailure    bool
+	RequireSigned    bool
+	AllowedSigners   string
+	GPGKeyring       string
+	ReportStatus     bool
+	StatusContext    string
+	StatusURL        string
+	GitHubAPIURL     string
+	Plan             bool
+	PlanFormat       string
+	Version          bool
 }
 
 var osGetEnv = os.Getenv
+var filepathAbs = filepath.Abs
+
+// listFlag is a flag that can be given more than once, or as a comma
+// separated list (which is how it's set from the environment or config)
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// legacyEnvVars are older environment variable names that still work for a flag
+var legacyEnvVars = map[string]string{
+	"https-username": "SCM_USERNAME",
+	"https-token":    "SCM_ACCESS_TOKEN",
+	"trace-endpoint": "OTEL_EXPORTER_OTLP_ENDPOINT",
+}
+
+// envVar is the environment variable for a flag, --https-token is BOOKEND_HTTPS_TOKEN
+func envVar(name string) string {
+	return "BOOKEND_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// envValue returns the value set in the environment for a flag (if any)
+func envValue(name string) (string, bool) {
+	if name == "version" {
+		return "", false
+	}
+	if value := osGetEnv(envVar(name)); value != "" {
+		return value, true
+	}
+	if legacy, ok := legacyEnvVars[name]; ok && osGetEnv(legacy) != "" {
+		return osGetEnv(legacy), true
+	}
+	return "", false
+}
 
-func getFlags(args []string) CommandArgs {
+func knownCommand(name string) bool {
+	for _, command := range commands {
+		if command.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func getFlags(args []string) (CommandArgs, error) {
 	var config CommandArgs
+	var configPath string
+
+	// Without a command we act like we always have, as a checkout
+	config.Command = "checkout"
+	rest := args[1:]
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		config.Command, rest = rest[0], rest[1:]
+	}
+	if !knownCommand(config.Command) {
+		return config, fmt.Errorf("unknown command %q", config.Command)
+	}
 
 	f := flag.NewFlagSet(args[0], flag.ExitOnError)
+	f.Usage = func() {
+		fmt.Fprintf(f.Output(), "Usage: %s [command] [flags]\n\nCommands:\n", args[0])
+		for _, command := range commands {
+			fmt.Fprintf(f.Output(), "  %-10s%s\n", command.name, command.description)
+		}
+		fmt.Fprintf(f.Output(), "\nFlags:\n")
+		f.PrintDefaults()
+	}
 
 	f.StringVar(&config.Host, "host", "", "Repository Host")
 	f.StringVar(&config.Repo, "repo", "", "Repository Org/Repo")
 	f.StringVar(&config.Branch, "branch", "master", "Checkout branch")
 	f.StringVar(&config.SHA, "sha", "", "Commit SHA1")
+	f.StringVar(&config.BeforeSHA, "before-sha", "", "Commit the branch was at before the push, for --only-if-changed (default the parent of --sha)")
 
 	f.IntVar(&config.PullRequest, "pull-request", 0, "Pull Request Number")
 	f.StringVar(&config.TargetDir, "target-dir", "", "Checkout directory")
 	f.StringVar(&config.CloneMethod, "clone-method", "https", "Git Clone Method (https|ssh)")
+	f.StringVar(&config.Manifest, "manifest", "", "YAML or JSON file of other repositories to check out (in parallel) under --target-dir")
+	f.BoolVar(&config.KeepOnFailure, "keep-on-failure", false, "Keep the partial checkout in --target-dir when cancelled by a signal")
 
 	f.StringVar(&config.GitName, "git-name", "sd-buildbot", "Name in Git Config")
 	f.StringVar(&config.GitEmail, "git-email", "dev-null@screwdriver.cd", "Email in Git Config")
 
-	f.StringVar(&config.HTTPSUsername, "https-username", osGetEnv("SCM_USERNAME"), "Username to use when authenticating via HTTPS")
-	f.StringVar(&config.HTTPSToken, "https-token", osGetEnv("SCM_ACCESS_TOKEN"), "Token to use when authenticating via HTTPS")
+	f.StringVar(&config.HTTPSUsername, "https-username", "", "Username to use when authenticating via HTTPS")
+	f.StringVar(&config.HTTPSToken, "https-token", "", "Token to use when authenticating via HTTPS")
+
+	f.StringVar(&config.ResultFile, "result-file", "", "Write a JSON summary of the checkout to this file")
+	f.StringVar(&config.EnvFile, "env-file", "", "Write the checkout details as shell variables to this file")
+	f.StringVar(&config.ChangedFiles, "changed-files", "", "Write the files changed by the pull request to this file, as tab separated status, path and old path (for renames)")
+	f.StringVar(&config.ChangedFilesJSON, "changed-files-json", "", "Write the files changed by the pull request to this file as JSON")
+	f.Var((*listFlag)(&config.OnlyIfChanged), "only-if-changed", "Exit with 10 (nothing to build) unless a changed file matches one of these glob patterns, can be repeated or comma separated")
+	f.BoolVar(&config.SkipCI, "skip-ci", false, "Exit with 11 (skipped) before merging or resetting if the --sha commit message has a skip marker")
+	f.Var((*listFlag)(&config.SkipCIMarkers), "skip-ci-markers", "Markers for --skip-ci, can be repeated or comma separated (default [skip ci], [ci skip])")
+	f.StringVar(&config.MetricsFile, "metrics-file", "", "Write Prometheus metrics for the checkout to this file (e.g. in the node_exporter textfile directory)")
+	f.StringVar(&config.TraceEndpoint, "trace-endpoint", "", "Export an OpenTelemetry trace of the checkout to this OTLP/HTTP collector (e.g. http://localhost:4318)")
+	f.StringVar(&config.TraceFile, "trace-file", "", "Write an OpenTelemetry trace of the checkout to this file as OTLP/JSON")
+	f.StringVar(&config.MetaFile, "meta-file", "", "Screwdriver meta JSON file to record the checkout in (e.g. /sd/meta/meta.json)")
+
+	f.StringVar(&config.CommitMessage, "commit-message", "", "Teardown: commit any changes with this message")
+	f.StringVar(&config.Tag, "tag", "", "Teardown: create an annotated tag with this name and push it")
+	f.StringVar(&config.TagMessage, "tag-message", "", "Teardown: message for --tag (default the tag name)")
+	f.BoolVar(&config.AllowPRPush, "allow-pr-push", false, "Teardown: allow pushing from pull request builds")
 
+	f.BoolVar(&config.VerifyBranch, "verify-branch", true, "Fail unless --sha is on --branch (not used for pull requests)")
+	f.BoolVar(&config.RequireSigned, "require-signed", false, "Fail unless --sha (and every commit in the pull request) is signed by an allowed key")
+	f.StringVar(&config.AllowedSigners, "allowed-signers", "", "SSH allowed signers file for --require-signed")
+	f.StringVar(&config.GPGKeyring, "gpg-keyring", "", "GPG keyring with the allowed public keys for --require-signed")
+
+	f.BoolVar(&config.ReportStatus, "report-status", false, "Set a commit status on --sha while checking out (uses --https-token)")
+	f.StringVar(&config.StatusContext, "status-context", "bookend", "Context (name) of the commit status")
+	f.StringVar(&config.StatusURL, "status-target-url", "", "Link for the commit status, e.g. the build URL")
+	f.StringVar(&config.GitHubAPIURL, "github-api-url", "", "GitHub API URL (default https://api.github.com, or https://<host>/api/v3 for GitHub Enterprise)")
+
+	f.StringVar(&config.Backend, "backend", "exec", "Run Git with GIT_PATH (exec) or in-process where possible (native)")
+	f.StringVar(&config.Progress, "progress", "auto", "How to show Git's progress (auto|tty|plain|json|raw), auto is tty on a terminal and plain otherwise")
+	f.StringVar(&config.MinGitVersion, "min-git-version", "2.12.0", "Fail before starting if Git is older than this")
+	f.BoolVar(&config.IsolateGit, "isolate-git", true, "Run Git without the system and global config, hooks, prompts or stdin, and only over HTTPS or SSH")
+
+	f.BoolVar(&config.Plan, "plan", false, "Print the Git commands that would be run instead of running them")
+	f.StringVar(&config.PlanFormat, "plan-format", "text", "Format of --plan (text|shell), shell is a runnable script")
+
+	f.StringVar(&configPath, "config", "", "YAML or JSON file of default flag values (default ~/.config/bookend/config.yaml or /etc/bookend/config.yaml)")
 	f.BoolVar(&config.Version, "version", false, "Display Version number")
 
-	f.Parse(args[1:])
+	// Show which environment variables can be used in --help
+	f.VisitAll(func(fl *flag.Flag) {
+		if fl.Name == "version" {
+			return
+		}
+		fl.Usage = fmt.Sprintf("%s [$%s", fl.Usage, envVar(fl.Name))
+		if legacy, ok := legacyEnvVars[fl.Name]; ok {
+			fl.Usage += ", $" + legacy
+		}
+		fl.Usage += "]"
+	})
+
+	f.Parse(rest)
+	if f.NArg() > 0 {
+		return config, fmt.Errorf("unexpected argument %q", f.Arg(0))
+	}
+
+	if config.Version || config.Command == "version" {
+		config.Command, config.Version = "version", true
+	}
+
+	if configPath == "" {
+		configPath, _ = envValue("config")
+	}
+	if configPath == "" {
+		configPath = discoverConfigPath()
+	}
+	err := applyDefaults(f, configPath)
+
+	// Teardown commits as whoever the checkout configured, unless told otherwise
+	if config.Command == "teardown" {
+		given := map[string]bool{}
+		f.Visit(func(fl *flag.Flag) { given[fl.Name] = true })
+		if !given["git-name"] {
+			config.GitName = ""
+		}
+		if !given["git-email"] {
+			config.GitEmail = ""
+		}
+	}
 
 	config.ScmURL = fmt.Sprintf("%s/%s", config.Host, config.Repo)
 
-	return config
+	return config, err
 }
 
-func validateConfig(config CommandArgs) error {
-	if config.Host == "" {
-		return errors.New("--host is required")
-	}
-	if config.Repo == "" {
-		return errors.New("--repo is required")
+// applyDefaults fills in flags that weren't given on the command-line, first
+// from the environment and then from the config file (host section first)
+func applyDefaults(f *flag.FlagSet, configPath string) error {
+	var file configFile
+	var err error
+
+	if configPath != "" {
+		if file, err = loadConfigFile(configPath); err != nil {
+			return err
+		}
+		err = file.validate(func(name string) bool {
+			return f.Lookup(name) != nil && name != "config" && name != "version"
+		})
+		if err != nil {
+			return err
+		}
 	}
-	if config.SHA == "" {
-		return errors.New("--sha is required")
+
+	explicit := map[string]bool{}
+	f.Visit(func(fl *flag.Flag) { explicit[fl.Name] = true })
+
+	apply := func(name string) error {
+		if explicit[name] {
+			return nil
+		}
+		if value, ok := envValue(name); ok {
+			if err := f.Set(name, value); err != nil {
+				return fmt.Errorf("invalid value %q for $%s: %v", value, envVar(name), err)
+			}
+			return nil
+		}
+		if value, ok := file.lookup(f.Lookup("host").Value.String(), name); ok {
+			return value.set(f, name)
+		}
+		return nil
 	}
-	if config.TargetDir == "" {
-		return errors.New("--target-dir is required")
+
+	// The host has to be known before we can pick the matching section
+	if err = apply("host"); err != nil {
+		return err
 	}
-	return nil
+	f.VisitAll(func(fl *flag.Flag) {
+		if err == nil && fl.Name != "host" {
+			err = apply(fl.Name)
+		}
+	})
+	return err
 }
 
-func addDynamicConfig(config CommandArgs) (CommandArgs, error) {
+// cloneURL is where to clone repo on host from, with the HTTPS credentials
+// (if any) when auth is set
+func cloneURL(config CommandArgs, host, repo string, auth bool) (string, error) {
 	switch {
-	case config.CloneMethod == "https" && config.HTTPSUsername != "" && config.HTTPSToken != "":
-		config.CloneURL = fmt.Sprintf("https://%s:%s@%s/%s.git", config.HTTPSUsername, config.HTTPSToken, config.Host, config.Repo)
+	case config.CloneMethod == "https" && auth && config.HTTPSUsername != "" && config.HTTPSToken != "":
+		return fmt.Sprintf("https://%s:%s@%s/%s.git", config.HTTPSUsername, config.HTTPSToken, host, repo), nil
 	case config.CloneMethod == "https":
-		config.CloneURL = fmt.Sprintf("https://%s/%s.git", config.Host, config.Repo)
+		return fmt.Sprintf("https://%s/%s.git", host, repo), nil
 	case config.CloneMethod == "ssh":
-		config.CloneURL = fmt.Sprintf("git@%s:%s.git", config.Host, config.Repo)
+		return fmt.Sprintf("git@%s:%s.git", host, repo), nil
+	}
+	return "", errors.New("--clone-method must be https or ssh")
+}
+
+func addDynamicConfig(config CommandArgs) (CommandArgs, error) {
+	var err error
+	if config.CloneURL, err = cloneURL(config, config.Host, config.Repo, true); err != nil {
+		return config, err
+	}
+
+	if config.Backend != "exec" && config.Backend != "native" {
+		return config, errors.New("--backend must be exec or native")
+	}
+
+	switch config.Progress {
+	case "auto", "tty", "plain", "json", "raw":
 	default:
-		return config, errors.New("--clone-method must be https or ssh")
+		return config, errors.New("--progress must be auto, tty, plain, json or raw")
+	}
+
+	if config.PlanFormat != "text" && config.PlanFormat != "shell" {
+		return config, errors.New("--plan-format must be text or shell")
+	}
+	// A script can't check signatures the way we do, so it would build
+	// whatever it was given
+	if config.Plan && config.PlanFormat == "shell" && config.RequireSigned {
+		return config, errors.New("--require-signed can't be used with --plan-format=shell")
 	}
 
 	return config, nil
 }
 
-// GetArguments returns the flags and options set on the command-line
+// absolutePaths resolves the files we read or write against where we were
+// started, as the checkout changes into --target-dir before using them. The
+// allowed keys especially must never come from the repository being checked.
+func absolutePaths(config *CommandArgs) error {
+	paths := []*string{
+		&config.AllowedSigners,
+		&config.GPGKeyring,
+		&config.ResultFile,
+		&config.MetaFile,
+		&config.EnvFile,
+		&config.MetricsFile,
+		&config.TraceFile,
+		&config.ChangedFiles,
+		&config.ChangedFilesJSON,
+	}
+	for _, path := range paths {
+		if *path == "" {
+			continue
+		}
+		abs, err := filepathAbs(*path)
+		if err != nil {
+			return fmt.Errorf("unable to resolve %s: %v", *path, err)
+		}
+		*path = abs
+	}
+	return nil
+}
+
+// GetArguments returns the flags and options set on the command-line, in the
+// environment or in the config file (in that order of precedence)
 func GetArguments(args []string) (CommandArgs, error) {
-	config := getFlags(args)
-	err := validateConfig(config)
+	config, err := getFlags(args)
+	if err != nil || config.Command == "version" {
+		return config, err
+	}
 
+	err = validateConfig(config)
 	if err != nil {
 		return config, err
 	}
 
-	return addDynamicConfig(config)
+	if err = absolutePaths(&config); err != nil {
+		return config, err
+	}
+
+	config, err = addDynamicConfig(config)
+	if err != nil || config.Manifest == "" || config.Command != "checkout" {
+		return config, err
+	}
+
+	config.ManifestRepos, err = loadManifest(config)
+	return config, err
 }
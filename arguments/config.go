@@ -0,0 +1,116 @@
+package arguments
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var osStat = os.Stat
+var readFile = ioutil.ReadFile
+
+// configFile is the contents of a --config file. Keys are the same as the
+// command-line flags (without the dashes); anything under hosts.<host> only
+// applies when --host matches.
+type configFile struct {
+	Values map[string]configValue            `yaml:",inline"`
+	Hosts  map[string]map[string]configValue `yaml:"hosts"`
+}
+
+// configValue is a single value in a config file, or a list of them for the
+// flags that can be repeated
+type configValue struct {
+	values []string
+	list   bool
+}
+
+func (v *configValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&v.values); err == nil {
+		v.list = true
+		return nil
+	}
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	v.values = []string{value}
+	return nil
+}
+
+// set gives the flag name the value, one item at a time for lists
+func (v configValue) set(f *flag.FlagSet, name string) error {
+	if _, repeatable := f.Lookup(name).Value.(*listFlag); v.list && !repeatable {
+		return fmt.Errorf("a list isn't allowed for %s in config", name)
+	}
+	for _, value := range v.values {
+		if err := f.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s in config: %v", value, name, err)
+		}
+	}
+	return nil
+}
+
+// defaultConfigPaths are checked in order when --config is not given
+func defaultConfigPaths() []string {
+	paths := []string{}
+	if home := osGetEnv("HOME"); home != "" {
+		paths = append(paths, filepath.Join(home, ".config", "bookend", "config.yaml"))
+	}
+	return append(paths, "/etc/bookend/config.yaml")
+}
+
+// discoverConfigPath returns the first default config file that exists
+func discoverConfigPath() string {
+	for _, path := range defaultConfigPaths() {
+		if _, err := osStat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadConfigFile reads a YAML (or JSON) config file
+func loadConfigFile(path string) (configFile, error) {
+	var file configFile
+
+	contents, err := readFile(path)
+	if err != nil {
+		return file, fmt.Errorf("unable to read config %s: %v", path, err)
+	}
+	if err = yaml.Unmarshal(contents, &file); err != nil {
+		return file, fmt.Errorf("unable to parse config %s: %v", path, err)
+	}
+	return file, nil
+}
+
+// lookup returns the value for a flag, preferring the section for host
+func (c configFile) lookup(host, name string) (configValue, bool) {
+	if section, ok := c.Hosts[host]; ok {
+		if value, ok := section[name]; ok {
+			return value, true
+		}
+	}
+	value, ok := c.Values[name]
+	return value, ok
+}
+
+// validate makes sure every key in the file is a flag we know about
+func (c configFile) validate(known func(string) bool) error {
+	for name := range c.Values {
+		if !known(name) {
+			return fmt.Errorf("unknown option %q in config", name)
+		}
+	}
+	for host, section := range c.Hosts {
+		for name := range section {
+			if !known(name) || name == "host" {
+				return fmt.Errorf("unknown option %q for host %s in config", name, host)
+			}
+		}
+	}
+	return nil
+}
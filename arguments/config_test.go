@@ -0,0 +1,232 @@
+package arguments
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfig(contents string, t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "arguments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err = ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestConfigFile(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "" }
+	path, cleanup := writeConfig(`
+host: github.com
+clone-method: ssh
+git-name: from-file
+git-email: file@example.com
+`, t)
+	defer cleanup()
+
+	args, err := GetArguments([]string{
+		"fakeapp",
+		"--config=" + path,
+		"--repo=testOrg/testRepo",
+		"--sha=302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+		"--target-dir=/tmp/foo",
+		"--git-name=from-flag",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if args.Host != "github.com" || args.CloneMethod != "ssh" || args.CloneURL != "git@github.com:testOrg/testRepo.git" {
+		t.Errorf("Expected the config file to be used, got %+v", args)
+	}
+	if args.GitName != "from-flag" {
+		t.Errorf("Expected flags to win over the config file, got %v", args.GitName)
+	}
+	if args.GitEmail != "file@example.com" {
+		t.Errorf("Expected the config file to win over defaults, got %v", args.GitEmail)
+	}
+}
+
+func TestConfigFileJSON(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "" }
+	path, cleanup := writeConfig(`{"clone-method": "ssh", "pull-request": 15}`, t)
+	defer cleanup()
+
+	args, err := GetArguments([]string{
+		"fakeapp",
+		"--config", path,
+		"--host=github.com",
+		"--repo=testOrg/testRepo",
+		"--sha=302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+		"--target-dir=/tmp/foo",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if args.CloneMethod != "ssh" || args.PullRequest != 15 {
+		t.Errorf("Expected the JSON config file to be used, got %+v", args)
+	}
+}
+
+func TestConfigFileHostSection(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "" }
+	path, cleanup := writeConfig(`
+clone-method: https
+git-name: everyone
+hosts:
+  ghe.example.com:
+    clone-method: ssh
+    git-name: enterprise-bot
+`, t)
+	defer cleanup()
+
+	base := []string{
+		"fakeapp",
+		"--config=" + path,
+		"--repo=testOrg/testRepo",
+		"--sha=302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+		"--target-dir=/tmp/foo",
+	}
+
+	args, err := GetArguments(append(base, "--host=ghe.example.com"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if args.CloneMethod != "ssh" || args.GitName != "enterprise-bot" {
+		t.Errorf("Expected the host section to be used, got %+v", args)
+	}
+
+	args, err = GetArguments(append(base, "--host=github.com"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if args.CloneMethod != "https" || args.GitName != "everyone" {
+		t.Errorf("Expected the top-level values to be used, got %+v", args)
+	}
+}
+
+func TestConfigFileEnvironment(t *testing.T) {
+	osGetEnv = func(variable string) (out string) {
+		if variable == "SCM_ACCESS_TOKEN" {
+			return "from-env"
+		}
+		return ""
+	}
+	path, cleanup := writeConfig(`
+https-username: stjohn
+https-token: from-file
+`, t)
+	defer cleanup()
+
+	args, err := GetArguments([]string{
+		"fakeapp",
+		"--config=" + path,
+		"--host=github.com",
+		"--repo=testOrg/testRepo",
+		"--sha=302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+		"--target-dir=/tmp/foo",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if args.HTTPSUsername != "stjohn" || args.HTTPSToken != "from-env" {
+		t.Errorf("Expected the environment to win over the config file, got %+v", args)
+	}
+}
+
+func TestConfigFileLists(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "" }
+	path, cleanup := writeConfig(`
+only-if-changed:
+  - services/api/**
+  - docs/*.md
+skip-ci-markers: "[skip ci], [no build]"
+hosts:
+  github.com:
+    skip-ci-markers: ["[ghe skip]"]
+`, t)
+	defer cleanup()
+
+	args, err := GetArguments([]string{
+		"fakeapp",
+		"--config=" + path,
+		"--host=github.com",
+		"--repo=testOrg/testRepo",
+		"--sha=302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+		"--target-dir=/tmp/foo",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if want := []string{"services/api/**", "docs/*.md"}; !reflect.DeepEqual(args.OnlyIfChanged, want) {
+		t.Errorf("Received the wrong patterns: %v, want %v", args.OnlyIfChanged, want)
+	}
+	if want := []string{"[ghe skip]"}; !reflect.DeepEqual(args.SkipCIMarkers, want) {
+		t.Errorf("Expected the host section's list to win, got %v", args.SkipCIMarkers)
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "" }
+	tests := map[string]string{
+		"foo: bar\n":                      `unknown option "foo" in config`,
+		"version: true\n":                 `unknown option "version" in config`,
+		"hosts:\n  a.com:\n    host: b\n": `unknown option "host" for host a.com in config`,
+		"pull-request: abc\n":             `invalid value "abc" for pull-request in config: parse error`,
+		"- not\n- a map\n":                "unable to parse config",
+		"git-name:\n  - a\n  - b\n":       `a list isn't allowed for git-name in config`,
+	}
+
+	for contents, want := range tests {
+		path, cleanup := writeConfig(contents, t)
+		_, err := GetArguments([]string{"fakeapp", "--config=" + path})
+		cleanup()
+
+		if err == nil || len(err.Error()) < len(want) || err.Error()[:len(want)] != want {
+			t.Errorf("Received the wrong error for %q: %v, want %v", contents, err, want)
+		}
+	}
+}
+
+func TestConfigFileMissing(t *testing.T) {
+	_, err := GetArguments([]string{"fakeapp", "--config=/does/not/exist.yaml"})
+
+	want := "unable to read config /does/not/exist.yaml: open /does/not/exist.yaml: no such file or directory"
+	if err == nil || err.Error() != want {
+		t.Errorf("Received the wrong error: %v, want %v", err, want)
+	}
+}
+
+func TestConfigFileDiscovery(t *testing.T) {
+	osGetEnv = func(variable string) (out string) {
+		if variable == "HOME" {
+			return "/home/bookend"
+		}
+		return ""
+	}
+	checked := []string{}
+	stat := osStat
+	defer func() { osStat = stat }()
+	osStat = func(path string) (os.FileInfo, error) {
+		checked = append(checked, path)
+		if path == "/etc/bookend/config.yaml" {
+			return nil, nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	if got := discoverConfigPath(); got != "/etc/bookend/config.yaml" {
+		t.Errorf("Received the wrong config path: %v", got)
+	}
+	want := []string{"/home/bookend/.config/bookend/config.yaml", "/etc/bookend/config.yaml"}
+	if len(checked) != 2 || checked[0] != want[0] || checked[1] != want[1] {
+		t.Errorf("Checked the wrong paths: %v, want %v", checked, want)
+	}
+}
@@ -0,0 +1,94 @@
+package arguments
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ManifestRepo is a repository from --manifest, checked out alongside --repo
+type ManifestRepo struct {
+	// Host defaults to --host, only that host gets the HTTPS credentials
+	Host string `yaml:"host"`
+	Repo string `yaml:"repo"`
+	// Ref is the branch or tag to clone (default the remote's default branch)
+	Ref string `yaml:"ref"`
+	// SHA is the commit on Ref to check out (default the tip of Ref)
+	SHA string `yaml:"sha"`
+	// Path is the directory under --target-dir (default the repo's name)
+	Path     string `yaml:"path"`
+	CloneURL string `yaml:"-"`
+}
+
+// manifestFile is the contents of a --manifest file, like:
+//
+//	repos:
+//	  - repo: testOrg/protos
+//	    ref: main
+//	    sha: 302f5f5b48b9feee797a66c88811f1770bcb2dcf
+//	    path: third_party/protos
+type manifestFile struct {
+	Repos []ManifestRepo `yaml:"repos"`
+}
+
+// loadManifest reads the repositories in --manifest, listing every problem
+// with them at once like validateConfig
+func loadManifest(config CommandArgs) ([]ManifestRepo, error) {
+	contents, err := readFile(config.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest %s: %v", config.Manifest, err)
+	}
+	var file manifestFile
+	if err = yaml.UnmarshalStrict(contents, &file); err != nil {
+		return nil, fmt.Errorf("unable to parse manifest %s: %v", config.Manifest, err)
+	}
+
+	problems := []string{}
+	check := func(ok bool, format string, values ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, values...))
+		}
+	}
+
+	check(len(file.Repos) > 0, "manifest %s has no repos", config.Manifest)
+	paths := map[string]bool{}
+	for i, repo := range file.Repos {
+		if repo.Host == "" {
+			repo.Host = config.Host
+		}
+		if repo.Path == "" {
+			repo.Path = repo.Repo[strings.Index(repo.Repo, "/")+1:]
+		}
+		name := fmt.Sprintf("manifest repo %d", i+1)
+
+		check(validHost(repo.Host), "%s: host %q is not a valid host[:port]", name, repo.Host)
+		if !validRepo(repo.Repo) {
+			check(false, "%s: repo %q must be owner/name", name, repo.Repo)
+			continue
+		}
+		name = fmt.Sprintf("manifest repo %s", repo.Repo)
+		if repo.Ref != "" {
+			check(validBranch(repo.Ref), "%s: ref %q is not a valid branch or tag name", name, repo.Ref)
+		}
+		if repo.SHA != "" {
+			check(shaPattern.MatchString(repo.SHA), "%s: sha %q must be a full 40 or 64 character hex object id", name, repo.SHA)
+		}
+
+		clean := path.Clean(repo.Path)
+		check(clean == repo.Path && !path.IsAbs(clean) && clean != "." && !strings.HasPrefix(clean, "../") && clean != ".." &&
+			clean != ".git" && !strings.HasPrefix(clean, ".git/"),
+			"%s: path %q must be a directory inside --target-dir", name, repo.Path)
+		check(!paths[clean], "%s: path %q is used more than once", name, repo.Path)
+		paths[clean] = true
+
+		repo.CloneURL, _ = cloneURL(config, repo.Host, repo.Repo, repo.Host == config.Host)
+		file.Repos[i] = repo
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return file.Repos, nil
+}
@@ -0,0 +1,151 @@
+package arguments
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var readDir = ioutil.ReadDir
+
+var shaPattern = regexp.MustCompile(`^([0-9a-fA-F]{40}|[0-9a-fA-F]{64})$`)
+var repoPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*/[A-Za-z0-9_.-]+$`)
+var versionPattern = regexp.MustCompile(`^v?[0-9]+\.[0-9]+(\.[0-9]+)?$`)
+var hostLabelPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?$`)
+
+// validHost checks for a hostname or IPv4 address with an optional port
+func validHost(host string) bool {
+	if i := strings.LastIndex(host, ":"); i != -1 {
+		port, err := strconv.Atoi(host[i+1:])
+		if err != nil || port < 1 || port > 65535 {
+			return false
+		}
+		host = host[:i]
+	}
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if len(label) > 63 || !hostLabelPattern.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// validRepo checks for owner/name
+func validRepo(repo string) bool {
+	if !repoPattern.MatchString(repo) {
+		return false
+	}
+	name := repo[strings.Index(repo, "/")+1:]
+	return name != "." && name != ".."
+}
+
+// validBranch follows the rules of `git check-ref-format --branch`
+func validBranch(branch string) bool {
+	if branch == "" || branch == "@" || branch[0] == '-' {
+		return false
+	}
+	if strings.HasPrefix(branch, "/") || strings.HasSuffix(branch, "/") || strings.HasSuffix(branch, ".") {
+		return false
+	}
+	for _, bad := range []string{"..", "//", "@{", "\\", " ", "~", "^", ":", "?", "*", "["} {
+		if strings.Contains(branch, bad) {
+			return false
+		}
+	}
+	for _, char := range branch {
+		if char < 0x20 || char == 0x7f {
+			return false
+		}
+	}
+	for _, component := range strings.Split(branch, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// emptyOrMissing checks that we can clone into the directory
+func emptyOrMissing(dir string) bool {
+	files, err := readDir(dir)
+	if os.IsNotExist(err) {
+		return true
+	}
+	return err == nil && len(files) == 0
+}
+
+// existing checks that there is something in the directory to work with
+func existing(dir string) bool {
+	files, err := readDir(dir)
+	return err == nil && len(files) > 0
+}
+
+func validateConfig(config CommandArgs) error {
+	problems := []string{}
+	check := func(ok bool, format string, values ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, values...))
+		}
+	}
+	// Checkout needs everything, the other commands name what they need on top of that
+	required := func(name, value string, commands ...string) bool {
+		if value != "" {
+			return true
+		}
+		for _, command := range append(commands, "checkout") {
+			if config.Command == command {
+				problems = append(problems, fmt.Sprintf("--%s is required", name))
+			}
+		}
+		return false
+	}
+
+	if required("host", config.Host, "teardown") {
+		check(validHost(config.Host), "--host %q is not a valid host[:port]", config.Host)
+	}
+	if required("repo", config.Repo, "teardown") {
+		check(validRepo(config.Repo), "--repo %q must be owner/name", config.Repo)
+	}
+	check(validBranch(config.Branch), "--branch %q is not a valid branch name", config.Branch)
+	if required("sha", config.SHA, "verify") {
+		check(shaPattern.MatchString(config.SHA), "--sha %q must be a full 40 or 64 character hex object id", config.SHA)
+	}
+	if config.BeforeSHA != "" {
+		check(shaPattern.MatchString(config.BeforeSHA), "--before-sha %q must be a full 40 or 64 character hex object id", config.BeforeSHA)
+	}
+	check(config.PullRequest >= 0, "--pull-request must be a positive number")
+
+	switch {
+	case !required("target-dir", config.TargetDir, "verify", "teardown"):
+	case config.Command == "checkout":
+		check(emptyOrMissing(config.TargetDir), "--target-dir %s must not exist or be empty", config.TargetDir)
+	case config.Command == "verify" || config.Command == "teardown":
+		check(existing(config.TargetDir), "--target-dir %s must be an existing checkout", config.TargetDir)
+	}
+
+	if config.MinGitVersion != "" {
+		check(versionPattern.MatchString(config.MinGitVersion), "--min-git-version %q must be a version like 2.20.0", config.MinGitVersion)
+	}
+
+	if config.RequireSigned {
+		check(config.AllowedSigners != "" || config.GPGKeyring != "", "--require-signed needs --allowed-signers or --gpg-keyring")
+	}
+
+	if config.Command == "teardown" {
+		if config.Tag != "" {
+			check(validBranch(config.Tag), "--tag %q is not a valid tag name", config.Tag)
+		}
+		check(config.PullRequest == 0 || config.AllowPRPush, "refusing to push from a pull request build without --allow-pr-push")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
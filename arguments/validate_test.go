@@ -0,0 +1,234 @@
+package arguments
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Don't let whatever is in /tmp/foo on this machine affect the tests
+	readDir = func(string) ([]os.FileInfo, error) { return nil, os.ErrNotExist }
+	// nor whatever config file is in $HOME or /etc/bookend
+	osStat = func(string) (os.FileInfo, error) { return nil, os.ErrNotExist }
+	os.Exit(m.Run())
+}
+
+func TestValidHost(t *testing.T) {
+	tests := map[string]bool{
+		"github.com":           true,
+		"ghe.example.com:8443": true,
+		"10.0.0.1":             true,
+		"localhost":            true,
+		"":                     false,
+		"github.com:":          false,
+		"github.com:99999":     false,
+		"https://github.com":   false,
+		"-github.com":          false,
+		"github..com":          false,
+		"git hub.com":          false,
+	}
+	for host, want := range tests {
+		if got := validHost(host); got != want {
+			t.Errorf("validHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestValidRepo(t *testing.T) {
+	tests := map[string]bool{
+		"testOrg/testRepo":       true,
+		"screwdriver-cd/ui.next": true,
+		"a_b/c-d":                true,
+		"foo":                    false,
+		"foo/":                   false,
+		"/foo":                   false,
+		"a/b/c":                  false,
+		"a/..":                   false,
+		"a/b c":                  false,
+	}
+	for repo, want := range tests {
+		if got := validRepo(repo); got != want {
+			t.Errorf("validRepo(%q) = %v, want %v", repo, got, want)
+		}
+	}
+}
+
+func TestValidBranch(t *testing.T) {
+	tests := map[string]bool{
+		"master":          true,
+		"feature/foo-bar": true,
+		"v1.0.0":          true,
+		"":                false,
+		"@":               false,
+		"-foo":            false,
+		"/foo":            false,
+		"foo/":            false,
+		"foo.":            false,
+		"foo..bar":        false,
+		"foo//bar":        false,
+		"foo@{1}":         false,
+		"foo bar":         false,
+		"foo~1":           false,
+		"foo^":            false,
+		"foo:bar":         false,
+		"foo?":            false,
+		"foo*":            false,
+		"foo[":            false,
+		"foo\\bar":        false,
+		"foo\tbar":        false,
+		".foo":            false,
+		"foo/.bar":        false,
+		"foo.lock":        false,
+	}
+	for branch, want := range tests {
+		if got := validBranch(branch); got != want {
+			t.Errorf("validBranch(%q) = %v, want %v", branch, got, want)
+		}
+	}
+}
+
+func TestValidateConfigAllProblems(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "" }
+	osArgs := []string{
+		"fakeapp",
+		"--host=https://github.com",
+		"--repo=foo",
+		"--branch=foo..bar",
+		"--sha=302f5f5",
+		"--before-sha=HEAD~1",
+		"--pull-request=-1",
+		"--min-git-version=latest",
+	}
+	_, err := GetArguments(osArgs)
+
+	wantErr := `--host "https://github.com" is not a valid host[:port]; ` +
+		`--repo "foo" must be owner/name; ` +
+		`--branch "foo..bar" is not a valid branch name; ` +
+		`--sha "302f5f5" must be a full 40 or 64 character hex object id; ` +
+		`--before-sha "HEAD~1" must be a full 40 or 64 character hex object id; ` +
+		`--pull-request must be a positive number; ` +
+		`--target-dir is required; ` +
+		`--min-git-version "latest" must be a version like 2.20.0`
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Received the wrong error: %v, want %v", err, wantErr)
+	}
+}
+
+func TestValidateConfigSha256(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "" }
+	osArgs := []string{
+		"fakeapp",
+		"--host=github.com",
+		"--repo=testOrg/testRepo",
+		"--sha=6f9a0c8e6d3c1b5e2f4a7d9c0b1e3f5a7c9d1e3f5a7b9c1d3e5f7a9b1c3d5e7f",
+		"--target-dir=/tmp/foo",
+	}
+	_, err := GetArguments(osArgs)
+
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestValidateConfigTargetDirNotEmpty(t *testing.T) {
+	readDir = ioutil.ReadDir
+	defer func() { readDir = func(string) ([]os.FileInfo, error) { return nil, os.ErrNotExist } }()
+
+	dir, err := ioutil.TempDir("", "arguments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := CommandArgs{
+		Command:   "checkout",
+		Host:      "github.com",
+		Repo:      "testOrg/testRepo",
+		Branch:    "master",
+		SHA:       "302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+		TargetDir: dir,
+	}
+	if err = validateConfig(config); err != nil {
+		t.Errorf("Expected an empty directory to be allowed, got %v", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("hi"), 0644)
+	err = validateConfig(config)
+	wantErr := "--target-dir " + dir + " must not exist or be empty"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Received the wrong error: %v, want %v", err, wantErr)
+	}
+}
+
+func TestValidateConfigVerify(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "" }
+
+	_, err := GetArguments([]string{"fakeapp", "verify"})
+	wantErr := "--sha is required; --target-dir is required"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Received the wrong error: %v, want %v", err, wantErr)
+	}
+
+	_, err = GetArguments([]string{
+		"fakeapp",
+		"verify",
+		"--sha=302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+		"--target-dir=/tmp/foo",
+	})
+	wantErr = "--target-dir /tmp/foo must be an existing checkout"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Received the wrong error: %v, want %v", err, wantErr)
+	}
+}
+
+func TestValidateConfigDoctor(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "" }
+
+	args, err := GetArguments([]string{"fakeapp", "doctor"})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if args.Command != "doctor" {
+		t.Errorf("Received the wrong command: %v, want doctor", args.Command)
+	}
+
+	_, err = GetArguments([]string{"fakeapp", "doctor", "--repo=foo"})
+	wantErr := `--repo "foo" must be owner/name`
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Received the wrong error: %v, want %v", err, wantErr)
+	}
+}
+
+func TestValidateConfigTeardown(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "" }
+	readDir = func(string) ([]os.FileInfo, error) { return []os.FileInfo{nil}, nil }
+	defer func() { readDir = func(string) ([]os.FileInfo, error) { return nil, os.ErrNotExist } }()
+
+	_, err := GetArguments([]string{"fakeapp", "teardown"})
+	wantErr := "--host is required; --repo is required; --target-dir is required"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Received the wrong error: %v, want %v", err, wantErr)
+	}
+
+	base := []string{"fakeapp", "teardown", "--host=github.com", "--repo=a/b", "--target-dir=/tmp/foo"}
+	args, err := GetArguments(append(base, "--tag=v1.0.0", "--commit-message=Bump"))
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if args.Tag != "v1.0.0" || args.CommitMessage != "Bump" {
+		t.Errorf("Received the wrong arguments: %+v", args)
+	}
+
+	_, err = GetArguments(append(base, "--tag=v1..0", "--pull-request=15"))
+	wantErr = `--tag "v1..0" is not a valid tag name; refusing to push from a pull request build without --allow-pr-push`
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Received the wrong error: %v, want %v", err, wantErr)
+	}
+
+	_, err = GetArguments(append(base, "--pull-request=15", "--allow-pr-push"))
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
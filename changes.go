@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/report"
+)
+
+// globPattern turns an --only-if-changed pattern into a regexp for the whole
+// path: * and ? stay within a directory, ** crosses them and a trailing /
+// matches everything under that directory
+func globPattern(pattern string) *regexp.Regexp {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+
+	var out strings.Builder
+	out.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			out.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			out.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			out.WriteString("[^/]*")
+		case pattern[i] == '?':
+			out.WriteString("[^/]")
+		default:
+			out.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	out.WriteString("$")
+	return regexp.MustCompile(out.String())
+}
+
+// matchChanged returns the first changed file that matches one of the
+// patterns (renames match on either side), and the pattern it matched
+func matchChanged(patterns []string, files []report.ChangedFile) (string, string) {
+	globs := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		globs[i] = globPattern(pattern)
+	}
+	for _, file := range files {
+		for _, path := range []string{file.Path, file.OldPath} {
+			for i, glob := range globs {
+				if path != "" && glob.MatchString(path) {
+					return path, patterns[i]
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// listChanges records the files changed on head since it diverged from base
+func listChanges(base, head string) {
+	mergeBase, err := getMergeBase(base, head)
+	if err != nil {
+		fail(exitInternal, fmt.Sprintf("%v\n", err))
+		return
+	}
+	result.MergeBaseSHA = mergeBase
+
+	changedFiles, err := getChangedFiles(mergeBase, head)
+	if err != nil {
+		fail(exitInternal, fmt.Sprintf("%v\n", err))
+		return
+	}
+	for _, file := range changedFiles {
+		result.ChangedFiles = append(result.ChangedFiles, report.ChangedFile(file))
+	}
+}
+
+// pushBase is what a push is compared with: --before-sha, or the parent of
+// --sha when that isn't given, created the branch or isn't in the clone
+// (e.g. after a force push). It's empty if there's nothing to compare with.
+func pushBase(args arguments.CommandArgs) string {
+	if strings.Trim(args.BeforeSHA, "0") != "" {
+		if _, err := getGitRevision(args.BeforeSHA); err == nil {
+			return args.BeforeSHA
+		}
+		fmtPrint(blackColor(fmt.Sprintf("\n%s is not in the clone, comparing with the parent of %s\n", args.BeforeSHA, args.SHA)))
+	}
+	parent, err := getGitRevision(args.SHA + "^")
+	if err != nil {
+		return ""
+	}
+	return parent
+}
+
+// onlyIfChanged returns why there's nothing to build when none of the
+// changed files match --only-if-changed, or "" if the build should go on
+func onlyIfChanged(args arguments.CommandArgs) string {
+	if len(args.OnlyIfChanged) == 0 {
+		return ""
+	}
+	patterns := strings.Join(args.OnlyIfChanged, ", ")
+	if args.Plan {
+		fmtPrint(greenColor(fmt.Sprintf("\n☛ Not checked by the plan: stopping with exit code %d unless a changed file matches %s\n", exitNothingToBuild, patterns)))
+		return ""
+	}
+	if result.MergeBaseSHA == "" {
+		fmtPrint(blackColor(fmt.Sprintf("\nNo earlier commit to compare %s with, so building it\n", result.HeadSHA)))
+		return ""
+	}
+
+	if path, pattern := matchChanged(args.OnlyIfChanged, result.ChangedFiles); path != "" {
+		fmtPrint(blackColor(fmt.Sprintf("\n%s matches %s\n", path, pattern)))
+		return ""
+	}
+	return fmt.Sprintf("Nothing to build, none of the %d changed files match %s", len(result.ChangedFiles), patterns)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+	"github.com/stjohnjohnson/bookend-scm-github/report"
+)
+
+func TestGlobPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"main.go", "main.go", true},
+		{"*.go", "main.go", true},
+		{"*.go", "git/git.go", false},
+		{"**/*.go", "git/git.go", true},
+		{"**/*.go", "main.go", true},
+		{"services/api/**", "services/api/cmd/main.go", true},
+		{"services/api/**", "services/apis/main.go", false},
+		{"services/api/", "services/api/README.md", true},
+		{"/docs/*.md", "docs/index.md", true},
+		{"docs/?.md", "docs/a.md", true},
+		{"docs/?.md", "docs/ab.md", false},
+		{"docs/**/index.md", "docs/index.md", true},
+		{"docs/**/index.md", "docs/a/b/index.md", true},
+		{"(a).txt", "(a).txt", true},
+		{"a+.txt", "aa.txt", false},
+	}
+	for _, test := range tests {
+		if got := globPattern(test.pattern).MatchString(test.path); got != test.match {
+			t.Errorf("Expected %q matching %q to be %v", test.pattern, test.path, test.match)
+		}
+	}
+}
+
+func TestMatchChanged(t *testing.T) {
+	files := []report.ChangedFile{
+		{Status: "modified", Path: "README.md"},
+		{Status: "renamed", Path: "services/web/main.go", OldPath: "services/api/main.go"},
+	}
+	path, pattern := matchChanged([]string{"docs/", "services/api/**"}, files)
+	if path != "services/api/main.go" || pattern != "services/api/**" {
+		t.Errorf("Received the wrong match: %q for %q", path, pattern)
+	}
+	if path, _ = matchChanged([]string{"docs/"}, files); path != "" {
+		t.Errorf("Expected no match, got %q", path)
+	}
+}
+
+func TestMainOnlyIfChangedSkips(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	resultPath := filepath.Join(dir, "result.json")
+
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"fetch --progress origin pull/15/head:pr",
+		"merge --no-edit ace893fb2c9553a38a873fb03d0e21a406b351a1",
+	}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(exitNothingToBuild, t)
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
+	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.PullRequest = 15
+		args.ResultFile = resultPath
+		args.OnlyIfChanged = []string{"services/api/**", "docs/"}
+	})
+	main()
+
+	got := readResult(resultPath, t)
+	want := "Nothing to build, none of the 2 changed files match services/api/**, docs/"
+	if !got.Success || got.ExitCode != exitNothingToBuild || got.SkipReason != want {
+		t.Errorf("Received the wrong result: %v / %v / %q, want %q", got.Success, got.ExitCode, got.SkipReason, want)
+	}
+}
+
+func TestMainOnlyIfChangedMatches(t *testing.T) {
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"fetch --progress origin pull/15/head:pr",
+		"merge --no-edit ace893fb2c9553a38a873fb03d0e21a406b351a1",
+	}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(0, t)
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
+	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.PullRequest = 15
+		args.OnlyIfChanged = []string{"services/api/**", "bookend.go"}
+	})
+	main()
+}
+
+func TestMainOnlyIfChangedPush(t *testing.T) {
+	defer restoreMocks()
+	before := "9b1e3f5a7c9d1e3f5a7b9c1d3e5f7a9b1c3d5e7f"
+
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"reset --hard ace893fb2c9553a38a873fb03d0e21a406b351a1",
+	}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	exited := exitOK
+	osExit = func(code int) { exited = code }
+	getGitVersion = mockVersion
+	getGitRevision = func(ref string) (string, error) {
+		if ref == before {
+			return before, nil
+		}
+		return mockRevision(ref)
+	}
+	getMergeBase = func(a, b string) (string, error) {
+		if a != before || b != "ace893fb2c9553a38a873fb03d0e21a406b351a1" {
+			t.Errorf("Compared the wrong commits: %v and %v", a, b)
+		}
+		return before, nil
+	}
+	getChangedFiles = func(base, head string) ([]git.ChangedFile, error) {
+		return []git.ChangedFile{{Status: "added", Path: "docs/index.md"}}, nil
+	}
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.BeforeSHA = before
+		args.OnlyIfChanged = []string{"services/"}
+	})
+	main()
+
+	if exited != exitNothingToBuild {
+		t.Errorf("Received the wrong exit code: %v, want %v", exited, exitNothingToBuild)
+	}
+}
+
+func TestMainOnlyIfChangedRootCommit(t *testing.T) {
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"reset --hard ace893fb2c9553a38a873fb03d0e21a406b351a1",
+	}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(0, t)
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = func(base, head string) ([]git.ChangedFile, error) {
+		t.Errorf("Expected nothing to compare %v with", head)
+		return nil, nil
+	}
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.OnlyIfChanged = []string{"services/"}
+	})
+	main()
+}
+
+func TestOnlyIfChangedPlan(t *testing.T) {
+	fmtPrint = mockPrint([]string{
+		"\n☛ Not checked by the plan: stopping with exit code 10 unless a changed file matches services/, docs/\n",
+	}, t)
+
+	if reason := onlyIfChanged(arguments.CommandArgs{OnlyIfChanged: []string{"services/", "docs/"}, Plan: true}); reason != "" {
+		t.Errorf("Expected the plan to go on, got %q", reason)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+)
+
+// doctor checks everything a checkout needs, reporting every problem it
+// finds and exiting with the code of the first one
+func doctor(args arguments.CommandArgs) {
+	code := exitOK
+	check := func(failCode int, message string) {
+		if failCode == exitOK {
+			fmtPrint(greenColor(fmt.Sprintf("✓ %s\n", message)))
+			return
+		}
+		fmtPrint(redColor(fmt.Sprintf("✗ %s\n", message)))
+		if code == exitOK {
+			code = failCode
+		}
+	}
+
+	fmtPrint(fmt.Sprintf("%s\tv%s\n\n", blackColor("Bookend:"), VERSION))
+
+	clientVersion, err := getGitVersion()
+	if err != nil {
+		check(exitGitMissing, fmt.Sprintf("Git at GIT_PATH=%q is unusable: %v", osGetEnv("GIT_PATH"), err))
+	} else if err = checkGitVersion(clientVersion, args); err != nil {
+		check(exitGitMissing, fmt.Sprintf("%v (GIT_PATH=%q)", err, osGetEnv("GIT_PATH")))
+	} else {
+		message := fmt.Sprintf("Git %s at %s", clientVersion, osGetEnv("GIT_PATH"))
+		if capabilities := clientVersion.Capabilities(); len(capabilities) > 0 {
+			names := []string{}
+			for _, capability := range capabilities {
+				names = append(names, string(capability))
+			}
+			message += ", supports " + strings.Join(names, " ")
+		}
+		check(exitOK, message)
+	}
+
+	if args.Host != "" && args.Repo != "" && code == exitOK {
+		err = executeStream("ls-remote", "--exit-code", "--heads", args.CloneURL, args.Branch)
+		if err != nil {
+			check(exitCodeFor(err), fmt.Sprintf("Unable to read branch %s of %s: %v", args.Branch, args.ScmURL, err))
+		} else {
+			check(exitOK, fmt.Sprintf("Can read branch %s of %s", args.Branch, args.ScmURL))
+		}
+	}
+
+	osExit(code)
+}
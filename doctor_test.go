@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+)
+
+func TestDoctor(t *testing.T) {
+	osGetEnv = func(string) string { return "/usr/bin/git" }
+	defer func() { osGetEnv = os.Getenv }()
+
+	executeStream = mockExec([]string{
+		"ls-remote --exit-code --heads https://github.com/testOrg/testRepo.git master",
+	}, t, false)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n\n",
+		"✓ Git v1.2.3 at /usr/bin/git\n",
+		"✓ Can read branch master of github.com/testOrg/testRepo\n",
+	}, t)
+	osExit = mockExit(0, t)
+	getGitVersion = mockVersion
+	getArguments = testArgs(func(args *arguments.CommandArgs) { args.Command = "doctor" })
+	main()
+}
+
+func TestDoctorNoGit(t *testing.T) {
+	osGetEnv = func(string) string { return "" }
+	defer func() { osGetEnv = os.Getenv }()
+
+	executeStream = mockExec([]string{}, t, false)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n\n",
+		"✗ Git at GIT_PATH=\"\" is unusable: Bad Version\n",
+	}, t)
+	osExit = mockExit(3, t)
+	getGitVersion = func() (git.Version, error) { return git.Version{}, errors.New("Bad Version") }
+	getArguments = testArgs(func(args *arguments.CommandArgs) { args.Command = "doctor" })
+	main()
+}
+
+func TestDoctorCapabilities(t *testing.T) {
+	osGetEnv = func(string) string { return "/usr/bin/git" }
+	defer func() { osGetEnv = os.Getenv }()
+
+	executeStream = mockExec([]string{}, t, false)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n\n",
+		"✓ Git v2.25.1 at /usr/bin/git, supports atomic-push protocol-allow protocol-v2 partial-clone sparse-cone\n",
+	}, t)
+	osExit = mockExit(0, t)
+	getGitVersion = func() (git.Version, error) { return git.ParseVersion("2.25.1") }
+	getArguments = func(_ []string) (arguments.CommandArgs, error) {
+		return arguments.CommandArgs{Command: "doctor", MinGitVersion: "2.20"}, nil
+	}
+	main()
+}
+
+func TestDoctorGitTooOld(t *testing.T) {
+	osGetEnv = func(string) string { return "/usr/bin/git" }
+	defer func() { osGetEnv = os.Getenv }()
+
+	executeStream = mockExec([]string{}, t, false)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n\n",
+		"✗ Git v1.2.3 is older than --min-git-version v2.20 (GIT_PATH=\"/usr/bin/git\")\n",
+	}, t)
+	osExit = mockExit(3, t)
+	getGitVersion = mockVersion
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.Command = "doctor"
+		args.MinGitVersion = "2.20"
+	})
+	main()
+}
+
+func TestDoctorNoAccess(t *testing.T) {
+	osGetEnv = func(string) string { return "/usr/bin/git" }
+	classifyFailure = func(error) git.Failure { return git.FailureAuth }
+	defer func() {
+		osGetEnv = os.Getenv
+		classifyFailure = git.Classify
+	}()
+
+	executeStream = mockExec([]string{
+		"ls-remote --exit-code --heads https://github.com/testOrg/testRepo.git master",
+	}, t, true)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n\n",
+		"✓ Git v1.2.3 at /usr/bin/git\n",
+		"✗ Unable to read branch master of github.com/testOrg/testRepo: Failed command\n",
+	}, t)
+	osExit = mockExit(5, t)
+	getGitVersion = mockVersion
+	getArguments = testArgs(func(args *arguments.CommandArgs) { args.Command = "doctor" })
+	main()
+}
@@ -0,0 +1,38 @@
+package main
+
+import "github.com/stjohnjohnson/bookend-scm-github/git"
+
+// Exit codes, so that whatever runs us can tell failures apart (documented in README.md)
+const (
+	exitOK                 = 0
+	exitInternal           = 1
+	exitInvalidArguments   = 2
+	exitGitMissing         = 3
+	exitNetwork            = 4
+	exitAuth               = 5
+	exitSHANotFound        = 6
+	exitMergeConflict      = 7
+	exitVerificationFailed = 8
+	exitInterrupted        = 9
+	exitNothingToBuild     = 10
+	exitSkipCI             = 11
+)
+
+var classifyFailure = git.Classify
+
+// exitCodeFor picks the exit code that describes why a Git command failed
+func exitCodeFor(err error) int {
+	switch classifyFailure(err) {
+	case git.FailureMissing:
+		return exitGitMissing
+	case git.FailureNetwork:
+		return exitNetwork
+	case git.FailureAuth:
+		return exitAuth
+	case git.FailureNotFound:
+		return exitSHANotFound
+	case git.FailureMergeConflict:
+		return exitMergeConflict
+	}
+	return exitInternal
+}
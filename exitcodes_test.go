@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	defer func() { classifyFailure = git.Classify }()
+
+	tests := map[git.Failure]int{
+		git.FailureUnknown:       1,
+		git.FailureMissing:       3,
+		git.FailureNetwork:       4,
+		git.FailureAuth:          5,
+		git.FailureNotFound:      6,
+		git.FailureMergeConflict: 7,
+	}
+	for failure, want := range tests {
+		classifyFailure = func(error) git.Failure { return failure }
+		if got := exitCodeFor(errors.New("Failed command")); got != want {
+			t.Errorf("Received the wrong exit code for %v: %v, want %v", failure, got, want)
+		}
+	}
+}
+
+func TestMainMergeConflict(t *testing.T) {
+	classifyFailure = func(error) git.Failure { return git.FailureMergeConflict }
+	defer func() { classifyFailure = git.Classify }()
+
+	executeStream = func(args ...string) error {
+		if args[0] == "merge" {
+			return errors.New("Command failed: exit status 1")
+		}
+		return nil
+	}
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	exited := []int{}
+	osExit = func(code int) { exited = append(exited, code) }
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
+	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
+
+	getArguments = func(_ []string) (arguments.CommandArgs, error) {
+		return arguments.CommandArgs{
+			Host:        "github.com",
+			Repo:        "testOrg/testRepo",
+			Branch:      "master",
+			SHA:         "ace893fb2c9553a38a873fb03d0e21a406b351a1",
+			PullRequest: 15,
+			TargetDir:   "/tmp/foo",
+		}, nil
+	}
+	main()
+
+	if len(exited) != 1 || exited[0] != 7 {
+		t.Errorf("Received the wrong exit codes: %v, want [7]", exited)
+	}
+}
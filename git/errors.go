@@ -0,0 +1,146 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Failure is the kind of problem that made a Git command fail
+type Failure int
+
+// Failures we can tell apart from Git's output
+const (
+	FailureUnknown Failure = iota
+	FailureMissing
+	FailureNetwork
+	FailureAuth
+	FailureNotFound
+	FailureMergeConflict
+)
+
+// maxOutput is how much of a command's output we keep for classifying failures
+const maxOutput = 64 * 1024
+
+// CommandError is returned when a Git command could not run or exited unsuccessfully
+type CommandError struct {
+	Args    []string
+	Output  string
+	Err     error
+	started bool
+}
+
+func (e *CommandError) Error() string {
+	if !e.started {
+		return fmt.Sprintf("Command wouldn't start: %v", e.Err)
+	}
+	return fmt.Sprintf("Command failed: %v", e.Err)
+}
+
+// Patterns are checked in order, so the more specific ones (authentication)
+// come before the generic connection errors they are usually reported with
+var failurePatterns = []struct {
+	failure  Failure
+	patterns []string
+}{
+	{FailureMergeConflict, []string{
+		"CONFLICT (",
+		"Automatic merge failed",
+		"fix conflicts and then commit",
+	}},
+	{FailureAuth, []string{
+		"Authentication failed",
+		"could not read Username",
+		"could not read Password",
+		"terminal prompts disabled",
+		"Permission denied (publickey",
+		"Host key verification failed",
+		"HTTP Basic: Access denied",
+		"Invalid username or password",
+		"returned error: 401",
+		"returned error: 403",
+		"Repository not found",
+		"authentication required",
+		"authorization failed",
+		"repository not found",
+	}},
+	{FailureNotFound, []string{
+		"unknown revision",
+		"not something we can merge",
+		"bad object",
+		"bad revision",
+		"reference is not a tree",
+		"did not match any",
+		"Couldn't find remote ref",
+		"not our ref",
+		"Needed a single revision",
+		"couldn't find remote ref",
+		"reference not found",
+		"object not found",
+	}},
+	{FailureNetwork, []string{
+		"Could not resolve host",
+		"Could not resolve hostname",
+		"unable to access",
+		"Connection refused",
+		"Connection timed out",
+		"Operation timed out",
+		"Failed to connect",
+		"Network is unreachable",
+		"the remote end hung up unexpectedly",
+		"early EOF",
+		"Could not read from remote repository",
+	}},
+}
+
+// Classify works out why a Git command failed by looking at what it printed
+func Classify(err error) Failure {
+	cmdErr, ok := err.(*CommandError)
+	if !ok {
+		return FailureUnknown
+	}
+	if !cmdErr.started {
+		return FailureMissing
+	}
+
+	for _, group := range failurePatterns {
+		for _, pattern := range group.patterns {
+			if strings.Contains(cmdErr.Output, pattern) {
+				return group.failure
+			}
+		}
+	}
+
+	// Anything else that goes wrong while talking to the remote is still a clone failure
+	if len(cmdErr.Args) > 0 {
+		switch cmdErr.Args[0] {
+		case "clone", "fetch", "ls-remote", "push":
+			return FailureNetwork
+		}
+	}
+	return FailureUnknown
+}
+
+// tailBuffer keeps the last maxOutput bytes written to it, stdout and stderr
+// can both write to it at once
+type tailBuffer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	n, err := t.buffer.Write(p)
+	if t.buffer.Len() > maxOutput {
+		t.buffer.Next(t.buffer.Len() - maxOutput)
+	}
+	return n, err
+}
+
+func (t *tailBuffer) String() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.buffer.String()
+}
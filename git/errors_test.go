@@ -0,0 +1,61 @@
+package git
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		args   string
+		output string
+		want   Failure
+	}{
+		{"clone", "fatal: unable to access 'https://github.com/a/b.git/': Could not resolve host: github.com", FailureNetwork},
+		{"clone", "fatal: Authentication failed for 'https://github.com/a/b.git/'", FailureAuth},
+		{"clone", "fatal: could not read Username for 'https://github.com': terminal prompts disabled", FailureAuth},
+		{"clone", "git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.", FailureAuth},
+		{"clone", "remote: Repository not found.\nfatal: repository 'https://github.com/a/b.git/' not found", FailureAuth},
+		{"clone", "fatal: Remote branch nope not found in upstream origin", FailureNetwork},
+		{"fetch", "fatal: Couldn't find remote ref pull/15/head", FailureNotFound},
+		{"reset", "fatal: ambiguous argument 'abc': unknown revision or path not in the working tree.", FailureNotFound},
+		{"merge", "merge: abc - not something we can merge", FailureNotFound},
+		{"merge", "CONFLICT (content): Merge conflict in README.md\nAutomatic merge failed; fix conflicts and then commit the result.", FailureMergeConflict},
+		{"config", "error: could not lock config file .git/config: File exists", FailureUnknown},
+	}
+
+	for _, test := range tests {
+		err := &CommandError{
+			Args:    strings.Split(test.args, " "),
+			Output:  test.output,
+			Err:     errors.New("exit status 128"),
+			started: true,
+		}
+		if got := Classify(err); got != test.want {
+			t.Errorf("Received the wrong failure for %q: %v, want %v", test.output, got, test.want)
+		}
+	}
+}
+
+func TestClassifyOtherErrors(t *testing.T) {
+	if got := Classify(errors.New("boom")); got != FailureUnknown {
+		t.Errorf("Received the wrong failure: %v, want %v", got, FailureUnknown)
+	}
+	if got := Classify(&CommandError{Err: errors.New("no such file")}); got != FailureMissing {
+		t.Errorf("Received the wrong failure: %v, want %v", got, FailureMissing)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	var buffer tailBuffer
+	buffer.Write([]byte(strings.Repeat("a", maxOutput)))
+	buffer.Write([]byte("CONFLICT"))
+
+	if len(buffer.String()) != maxOutput {
+		t.Errorf("Received the wrong length: %v, want %v", len(buffer.String()), maxOutput)
+	}
+	if !strings.HasSuffix(buffer.String(), "CONFLICT") {
+		t.Errorf("Expected the latest output to be kept")
+	}
+}
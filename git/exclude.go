@@ -0,0 +1,39 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// excludeEscaper escapes what .gitignore patterns treat as wildcards
+var excludeEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`)
+
+// ExcludePattern is the .gitignore pattern for just the directory at path
+func ExcludePattern(path string) string {
+	return "/" + excludeEscaper.Replace(path) + "/"
+}
+
+// Exclude keeps directories (relative to the top of the repository in dir,
+// "" being the current directory) out of git status and git add, by adding
+// them to its .git/info/exclude
+func Exclude(dir string, paths ...string) error {
+	info := filepath.Join(dir, ".git", "info")
+	if err := os.MkdirAll(info, 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(info, "exclude"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	var lines strings.Builder
+	for _, path := range paths {
+		lines.WriteString(ExcludePattern(path) + "\n")
+	}
+	if _, err = file.WriteString(lines.String()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
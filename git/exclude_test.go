@@ -0,0 +1,30 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exclude := filepath.Join(dir, ".git", "info", "exclude")
+
+	if err = Exclude(dir, "protos"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err = Exclude(dir, "shared/config", "odd[1]*"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	got, _ := ioutil.ReadFile(exclude)
+	want := "/protos/\n/shared/config/\n/odd\\[1]\\*/\n"
+	if string(got) != want {
+		t.Errorf("Received the wrong exclude file: %q, want %q", got, want)
+	}
+}
@@ -1,25 +1,71 @@
 package git
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"regexp"
+	"strings"
 )
 
 var osGetEnv = os.Getenv
 var execCommand = exec.Command
 
+// extraEnv is added to the environment of every Git command
+var extraEnv []string
+
+// Setenv sets an environment variable for every Git command we run after this
+func Setenv(key, value string) {
+	extraEnv = append(extraEnv, key+"="+value)
+}
+
+// extraConfig is given to every Git command as -c options
+var extraConfig []string
+
+// SetConfig sets a config value for every Git command we run after this
+func SetConfig(key, value string) {
+	extraConfig = append(extraConfig, "-c", key+"="+value)
+}
+
+func command(arguments ...string) *exec.Cmd {
+	if len(extraConfig) > 0 {
+		arguments = append(append([]string{}, extraConfig...), arguments...)
+	}
+	if isolated {
+		arguments = append(append([]string{}, isolatedArgs...), arguments...)
+	}
+	cmd := execCommand(osGetEnv("GIT_PATH"), arguments...)
+	if len(extraEnv) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, extraEnv...)
+	}
+	return cmd
+}
+
 // GetGitVersion returns the version of Git that we're using
-func GetGitVersion() (string, error) {
+func GetGitVersion() (Version, error) {
+	if native {
+		return Version{Name: nativeVersion}, nil
+	}
 	out, err := ExecuteReturn("--version")
 	if err != nil {
-		return "", fmt.Errorf("Unable to get Git version: %v", err)
+		return Version{}, fmt.Errorf("Unable to get Git version: %v", err)
+	}
+	match := regexp.MustCompile("git version (.*)").FindStringSubmatch(out)
+	if match == nil {
+		return Version{}, fmt.Errorf("Unable to get Git version from %q", strings.TrimSpace(out))
 	}
-	re := regexp.MustCompile("git version (.*)")
-	match := re.FindStringSubmatch(out)
 
-	return fmt.Sprintf("v%s", match[1]), nil
+	version, err := ParseVersion(match[1])
+	if err != nil {
+		// Unusual builds still work, we just can't tell what they support
+		version = Version{Name: "v" + match[1]}
+	}
+	return version, nil
 }
 
 // GetGitSha returns the current SHA
@@ -28,33 +74,241 @@ func GetGitSha() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("Unable to get current Git revision: %v", err)
 	}
-	return out, nil
+	return strings.TrimSpace(out), nil
+}
+
+// GetGitRevision returns the SHA that a ref points to
+func GetGitRevision(ref string) (string, error) {
+	out, err := ExecuteReturn("rev-parse", "--verify", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("Unable to resolve %s: %v", ref, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// HasChanges checks whether the working tree has anything to commit
+func HasChanges() (bool, error) {
+	out, err := ExecuteReturn("status", "--porcelain")
+	if err != nil {
+		return false, fmt.Errorf("Unable to get Git status: %v", err)
+	}
+	return strings.TrimSpace(out) != "", nil
+}
+
+// IsAncestor checks whether ancestor is reachable from descendant
+func IsAncestor(ancestor, descendant string) (bool, error) {
+	_, err := ExecuteReturn("merge-base", "--is-ancestor", ancestor, descendant)
+	if exitErr, ok := err.(*exec.ExitError); (ok && exitErr.ExitCode() == 1) || err == errNotAncestor {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Unable to compare %s and %s: %v", ancestor, descendant, err)
+	}
+	return true, nil
+}
+
+// CommitMessage is the full message of a commit
+type CommitMessage struct {
+	SHA     string
+	Message string
+}
+
+// CommitMessagesArgs are the arguments GetCommitMessages runs Git with
+func CommitMessagesArgs(revisions ...string) []string {
+	args := []string{"log", "--format=%H%x00%B%x00"}
+	return append(append(args, revisions...), "--")
+}
+
+// GetCommitMessages returns the message of every commit in revisions (as
+// accepted by git log)
+func GetCommitMessages(revisions ...string) ([]CommitMessage, error) {
+	out, err := ExecuteReturn(CommitMessagesArgs(revisions...)...)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get the commit messages of %s: %v", strings.Join(revisions, " "), err)
+	}
+
+	messages := []CommitMessage{}
+	fields := strings.Split(out, "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		messages = append(messages, CommitMessage{SHA: strings.TrimSpace(fields[i]), Message: strings.TrimSpace(fields[i+1])})
+	}
+	return messages, nil
+}
+
+// Signature is how a commit was signed
+type Signature struct {
+	SHA string
+	// Status is Git's %G? for the commit, G is a good signature
+	Status string
+	Key    string
+	Signer string
+}
+
+// SignatureArgs are the arguments GetSignatures runs Git with
+func SignatureArgs(allowedSigners string, revisions ...string) []string {
+	args := []string{}
+	if allowedSigners != "" {
+		args = append(args, "-c", "gpg.ssh.allowedSignersFile="+allowedSigners)
+	}
+	args = append(args, "log", "--format=%H%x00%G?%x00%GK%x00%GS")
+	return append(append(args, revisions...), "--")
+}
+
+// GetSignatures returns the signature of every commit in revisions (as
+// accepted by git log), checking SSH signatures against allowedSigners
+func GetSignatures(allowedSigners string, revisions ...string) ([]Signature, error) {
+	out, err := ExecuteReturn(SignatureArgs(allowedSigners, revisions...)...)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to check signatures: %v", err)
+	}
+
+	signatures := []Signature{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.SplitN(line, "\x00", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		signatures = append(signatures, Signature{SHA: fields[0], Status: fields[1], Key: fields[2], Signer: fields[3]})
+	}
+	return signatures, nil
+}
+
+// ChangedFile is a file that was changed between two commits
+type ChangedFile struct {
+	// Status is added, modified, renamed, copied or deleted
+	Status string
+	Path   string
+	// OldPath is where a renamed or copied file came from
+	OldPath string
+}
+
+// changeStatuses are the names for the letters in git diff --name-status
+var changeStatuses = map[byte]string{
+	'A': "added",
+	'M': "modified",
+	'T': "modified",
+	'D': "deleted",
+	'R': "renamed",
+	'C': "copied",
+}
+
+// ChangedFilesArgs are the arguments GetChangedFiles runs Git with
+func ChangedFilesArgs(base, head string) []string {
+	return []string{"diff", "--name-status", "-z", "-M", base + "..." + head}
+}
+
+// GetMergeBase returns the best common ancestor of two commits
+func GetMergeBase(a, b string) (string, error) {
+	out, err := ExecuteReturn("merge-base", a, b)
+	if err != nil {
+		return "", fmt.Errorf("Unable to find the merge base of %s and %s: %v", a, b, err)
+	}
+	return strings.TrimSpace(out), nil
 }
 
-// ExecuteStream will stream the input/output from a Git call
+// GetChangedFiles returns the files changed on head since it diverged from base
+func GetChangedFiles(base, head string) ([]ChangedFile, error) {
+	out, err := ExecuteReturn(ChangedFilesArgs(base, head)...)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list changed files: %v", err)
+	}
+
+	// Each file is a status and a path, renames and copies have the old path first
+	files := []ChangedFile{}
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "" {
+			continue
+		}
+		file := ChangedFile{Status: changeStatuses[fields[i][0]], Path: fields[i+1]}
+		if file.Status == "" {
+			file.Status = "modified"
+		}
+		if (file.Status == "renamed" || file.Status == "copied") && i+2 < len(fields) {
+			file.OldPath, file.Path = fields[i+1], fields[i+2]
+			i++
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// ExecuteStream will stream the input/output from a Git call, showing
+// Git's progress the way SetProgress asked for
 func ExecuteStream(arguments ...string) error {
-	cmd := execCommand(osGetEnv("GIT_PATH"), arguments...)
+	progress := newProgressWriter(os.Stderr, progressMode)
+	defer func() {
+		progress.Close()
+		received = progress.received
+		nativeProgress = os.Stderr
+	}()
+
+	nativeProgress = progress
+	if out, ok, err := nativeCommand(arguments); ok {
+		fmt.Print(out)
+		if err != nil {
+			return &CommandError{Args: arguments, Output: err.Error(), Err: err, started: true}
+		}
+		return nil
+	}
+
+	cmd := command(arguments...)
 
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// Keep a copy of the output so failures can be classified afterwards
+	var output tailBuffer
+	if !isolated {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stdout = io.MultiWriter(os.Stdout, &output)
+	cmd.Stderr = io.MultiWriter(progress, &output)
 
-	err := cmd.Start()
+	err := start(cmd)
 	if err != nil {
-		return fmt.Errorf("Command wouldn't start: %v", err)
+		return &CommandError{Args: arguments, Err: err}
 	}
 
-	err = cmd.Wait()
+	err = wait(cmd)
 	if err != nil {
-		return fmt.Errorf("Command failed: %v", err)
+		return &CommandError{Args: arguments, Output: output.String(), Err: err, started: true}
 	}
 
 	return nil
 }
 
+// ExecuteIn runs a Git command in dir and returns its output, keeping what it
+// printed to stderr for the error. Unlike the others it never runs in-process,
+// so it's safe to call from several goroutines at once.
+func ExecuteIn(dir string, arguments ...string) (string, error) {
+	var out bytes.Buffer
+	var output tailBuffer
+	cmd := command(arguments...)
+	cmd.Dir = dir
+	cmd.Stdout = io.MultiWriter(&out, &output)
+	cmd.Stderr = &output
+
+	if err := start(cmd); err != nil {
+		return "", &CommandError{Args: arguments, Err: err}
+	}
+	if err := wait(cmd); err != nil {
+		return out.String(), &CommandError{Args: arguments, Output: output.String(), Err: err, started: true}
+	}
+	return out.String(), nil
+}
+
 // ExecuteReturn will return the output from a Git call
 func ExecuteReturn(arguments ...string) (string, error) {
-	bytes, err := execCommand(osGetEnv("GIT_PATH"), arguments...).Output()
+	if out, ok, err := nativeCommand(arguments); ok {
+		return out, err
+	}
+
+	var out bytes.Buffer
+	cmd := command(arguments...)
+	cmd.Stdout = &out
+
+	err := start(cmd)
+	if err == nil {
+		err = wait(cmd)
+	}
 
-	return string(bytes), err
+	return out.String(), err
 }
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 type execFunc func(command string, args ...string) *exec.Cmd
@@ -51,6 +54,49 @@ func TestExecuteFailedCommand(t *testing.T) {
 	}
 }
 
+func TestExecuteWontStart(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/does/not/exist/git" }
+
+	err := ExecuteStream("status")
+
+	if err == nil || !strings.HasPrefix(err.Error(), "Command wouldn't start: ") {
+		t.Errorf("Expected the command not to start, got '%v'", err)
+	}
+	if Classify(err) != FailureMissing {
+		t.Errorf("Received the wrong failure: %v, want %v", Classify(err), FailureMissing)
+	}
+}
+
+func TestExecuteFailedOutput(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	err := ExecuteStream("merge", "ace893fb2c9553a38a873fb03d0e21a406b351a1")
+
+	if Classify(err) != FailureMergeConflict {
+		t.Errorf("Received the wrong failure for '%v': %v, want %v", err, Classify(err), FailureMergeConflict)
+	}
+}
+
+func TestExecuteIn(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	out, err := ExecuteIn(os.TempDir(), "foo")
+	if err != nil || out != "OK\n" {
+		t.Errorf("Received the wrong output: %q (%v)", out, err)
+	}
+
+	_, err = ExecuteIn(os.TempDir(), "merge")
+	if err == nil || Classify(err) != FailureMergeConflict {
+		t.Errorf("Expected a merge conflict, got %v", err)
+	}
+}
+
 func TestGetGitVersion(t *testing.T) {
 	osGetEnv = func(variable string) (out string) { return "/bin/git" }
 
@@ -62,9 +108,37 @@ func TestGetGitVersion(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
-	want := "v1.2.3"
+	want := Version{1, 2, 3, "v1.2.3"}
 	if version != want {
-		t.Errorf("Received the wrong version: %q, want %q", version, want)
+		t.Errorf("Received the wrong version: %v, want %v", version, want)
+	}
+}
+
+func TestGetGitVersionUnusual(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/unusual-git" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	version, err := GetGitVersion()
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if version.String() != "vcustom.build" || version.Known() {
+		t.Errorf("Received the wrong version: %#v", version)
+	}
+}
+
+func TestGetGitVersionNotGit(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/not-git" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	_, err := GetGitVersion()
+	want := `Unable to get Git version from "Usage: not-git"`
+	if err == nil || err.Error() != want {
+		t.Errorf("Expected '%v', got '%v'", want, err)
 	}
 }
 
@@ -81,9 +155,8 @@ func TestGetGitVersionFail(t *testing.T) {
 		t.Errorf("Expected '%v', got '%v'", want, err)
 	}
 
-	want = ""
-	if version != want {
-		t.Errorf("Received the wrong version: %q, want %q", version, want)
+	if version != (Version{}) {
+		t.Errorf("Received the wrong version: %v, want none", version)
 	}
 }
 
@@ -123,9 +196,296 @@ func TestGetGitShaFail(t *testing.T) {
 	}
 }
 
+func TestGetGitRevision(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = getFakeExecCommand(func(command string, args ...string) {
+		want := "rev-parse --verify pr^{commit}"
+		if got := strings.Join(args, " "); got != want {
+			t.Errorf("Received the wrong command: %v, want %v", got, want)
+		}
+	})
+	defer func() { execCommand = exec.Command }()
+
+	sha, err := GetGitRevision("pr")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	want := "302f5f5b48b9feee797a66c88811f1770bcb2dcf"
+	if sha != want {
+		t.Errorf("Received the wrong sha: %q, want %q", sha, want)
+	}
+}
+
+func TestGetGitRevisionFail(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/false" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	_, err := GetGitRevision("pr")
+
+	wantErr := "Unable to resolve pr: exit status 100"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Expected '%v', got '%v'", wantErr, err)
+	}
+}
+
+func TestGetChangedFiles(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = getFakeExecCommand(func(command string, args ...string) {
+		want := "diff --name-status -z -M abc...def"
+		if got := strings.Join(args, " "); got != want {
+			t.Errorf("Received the wrong command: %v, want %v", got, want)
+		}
+	})
+	defer func() { execCommand = exec.Command }()
+
+	files, err := GetChangedFiles("abc", "def")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	want := []ChangedFile{
+		{Status: "modified", Path: "README.md"},
+		{Status: "added", Path: "docs/with space.md"},
+		{Status: "renamed", Path: "new.go", OldPath: "old.go"},
+		{Status: "deleted", Path: "gone.txt"},
+		{Status: "modified", Path: "link"},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("Received the wrong files: %+v, want %+v", files, want)
+	}
+}
+
+func TestGetChangedFilesFail(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/false" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	_, err := GetChangedFiles("abc", "def")
+
+	wantErr := "Unable to list changed files: exit status 100"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Expected '%v', got '%v'", wantErr, err)
+	}
+}
+
+func TestGetMergeBase(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = getFakeExecCommand(func(command string, args ...string) {
+		want := "merge-base abc def"
+		if got := strings.Join(args, " "); got != want {
+			t.Errorf("Received the wrong command: %v, want %v", got, want)
+		}
+	})
+	defer func() { execCommand = exec.Command }()
+
+	sha, err := GetMergeBase("abc", "def")
+	if err != nil || sha != "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5" {
+		t.Errorf("Received the wrong merge base: %v (%v)", sha, err)
+	}
+}
+
+func TestGetMergeBaseFail(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/false" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	_, err := GetMergeBase("abc", "def")
+
+	wantErr := "Unable to find the merge base of abc and def: exit status 100"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Expected '%v', got '%v'", wantErr, err)
+	}
+}
+
+func TestGetCommitMessages(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = getFakeExecCommand(func(command string, args ...string) {
+		want := "log --format=%H%x00%B%x00 abc..def --"
+		if got := strings.Join(args, " "); got != want {
+			t.Errorf("Received the wrong command: %v, want %v", got, want)
+		}
+	})
+	defer func() { execCommand = exec.Command }()
+
+	messages, err := GetCommitMessages("abc..def")
+	want := []CommitMessage{
+		{SHA: "302f5f5b48b9feee797a66c88811f1770bcb2dcf", Message: "Fix the docs [skip ci]\n\nOnly the README changed"},
+		{SHA: "ace893fb2c9553a38a873fb03d0e21a406b351a1", Message: "Add the docs"},
+	}
+	if err != nil || !reflect.DeepEqual(messages, want) {
+		t.Errorf("Received the wrong messages: %q (%v), want %q", messages, err, want)
+	}
+}
+
+func TestGetCommitMessagesFail(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/false" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	_, err := GetCommitMessages("--max-count=1", "abc")
+
+	wantErr := "Unable to get the commit messages of --max-count=1 abc: exit status 100"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Expected '%v', got '%v'", wantErr, err)
+	}
+}
+
+func TestGetSignatures(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = getFakeExecCommand(func(command string, args ...string) {
+		want := "-c gpg.ssh.allowedSignersFile=/tmp/signers log --format=%H%x00%G?%x00%GK%x00%GS abc..def --"
+		if got := strings.Join(args, " "); got != want {
+			t.Errorf("Received the wrong command: %v, want %v", got, want)
+		}
+	})
+	defer func() { execCommand = exec.Command }()
+
+	signatures, err := GetSignatures("/tmp/signers", "abc..def")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	want := []Signature{
+		{SHA: "302f5f5b48b9feee797a66c88811f1770bcb2dcf", Status: "G", Key: "SHA256:abc", Signer: "stjohn@example.com"},
+		{SHA: "ace893fb2c9553a38a873fb03d0e21a406b351a1", Status: "N"},
+	}
+	if !reflect.DeepEqual(signatures, want) {
+		t.Errorf("Received the wrong signatures: %+v, want %+v", signatures, want)
+	}
+}
+
+func TestGetSignaturesFail(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/false" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	_, err := GetSignatures("", "abc")
+
+	wantErr := "Unable to check signatures: exit status 100"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Expected '%v', got '%v'", wantErr, err)
+	}
+}
+
+func TestSetenv(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = fakeExecCommand
+	defer func() {
+		execCommand = exec.Command
+		extraEnv = nil
+	}()
+
+	Setenv("GNUPGHOME", "/tmp/gnupg")
+	out, err := ExecuteReturn("env")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(out, "GNUPGHOME=/tmp/gnupg") {
+		t.Errorf("Expected GNUPGHOME to be set, got %q", out)
+	}
+}
+
+func TestHasChanges(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	changed, err := HasChanges()
+	if err != nil || !changed {
+		t.Errorf("Expected changes, got %v, %v", changed, err)
+	}
+}
+
+func TestHasChangesFail(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/false" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	_, err := HasChanges()
+
+	wantErr := "Unable to get Git status: exit status 100"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Expected '%v', got '%v'", wantErr, err)
+	}
+}
+
+func TestIsAncestor(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = getFakeExecCommand(func(command string, args ...string) {
+		want := "merge-base --is-ancestor abc def"
+		if got := strings.Join(args, " "); got != want && got != "merge-base --is-ancestor abc xyz" {
+			t.Errorf("Received the wrong command: %v, want %v", got, want)
+		}
+	})
+	defer func() { execCommand = exec.Command }()
+
+	ancestor, err := IsAncestor("abc", "def")
+	if err != nil || !ancestor {
+		t.Errorf("Expected abc to be an ancestor, got %v, %v", ancestor, err)
+	}
+
+	ancestor, err = IsAncestor("abc", "xyz")
+	if err != nil || ancestor {
+		t.Errorf("Expected abc not to be an ancestor, got %v, %v", ancestor, err)
+	}
+}
+
+func TestIsAncestorFail(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/false" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	_, err := IsAncestor("abc", "def")
+
+	wantErr := "Unable to compare abc and def: exit status 100"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Expected '%v', got '%v'", wantErr, err)
+	}
+}
+
 // This is a fake test for mocking out exec calls.
 // See https://golang.org/src/os/exec/exec_test.go and
 // https://npf.io/2015/06/testing-exec-command/ for more info
+func TestSetConfig(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	var args []string
+	execCommand = func(command string, arguments ...string) *exec.Cmd {
+		args = arguments
+		return fakeExecCommand(command, arguments...)
+	}
+	defer func() {
+		execCommand = exec.Command
+		extraConfig = nil
+	}()
+
+	SetConfig("protocol.version", "2")
+	if _, err := ExecuteReturn("--version"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	want := []string{"-c", "protocol.version=2", "--version"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Received the wrong arguments: %v, want %v", args, want)
+	}
+}
+
 func TestHelperProcess(*testing.T) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
 		return
@@ -141,13 +501,65 @@ func TestHelperProcess(*testing.T) {
 		}
 	}
 
+	// Isolated commands are the same as the rest after the extra config
+	for len(args) >= 3 && args[1] == "-c" && !strings.HasPrefix(args[2], "gpg.") {
+		args = append(args[:1:1], args[3:]...)
+	}
+
+	if len(args) == 2 && args[1] == "--version" {
+		switch args[0] {
+		case "/bin/unusual-git":
+			fmt.Println("git version custom.build")
+			return
+		case "/bin/not-git":
+			fmt.Println("Usage: not-git")
+			return
+		}
+	}
+
 	if len(args) >= 2 && args[0] == "/bin/git" {
 		switch args[1] {
 		default:
 			os.Exit(150)
+		case "sleep":
+			time.Sleep(10 * time.Second)
+			return
 		case "foo":
 			fmt.Println("OK")
 			return
+		case "clone":
+			fmt.Fprint(os.Stderr, "Receiving objects:  50% (1/2), 512.00 KiB | 1.01 MiB/s\r")
+			fmt.Fprint(os.Stderr, "Receiving objects: 100% (2/2), 1.50 MiB | 1.01 MiB/s, done.\n")
+			return
+		case "merge":
+			fmt.Println("CONFLICT (content): Merge conflict in README.md")
+			fmt.Fprintln(os.Stderr, "Automatic merge failed; fix conflicts and then commit the result.")
+			os.Exit(1)
+		case "-c":
+			fmt.Print("302f5f5b48b9feee797a66c88811f1770bcb2dcf\x00G\x00SHA256:abc\x00stjohn@example.com\n")
+			fmt.Print("ace893fb2c9553a38a873fb03d0e21a406b351a1\x00N\x00\x00\n")
+			return
+		case "env":
+			fmt.Print(strings.Join(os.Environ(), "\n"))
+			return
+		case "status":
+			fmt.Print(" M README.md\n")
+			return
+		case "log":
+			fmt.Print("302f5f5b48b9feee797a66c88811f1770bcb2dcf\x00Fix the docs [skip ci]\n\nOnly the README changed\n\x00\n")
+			fmt.Print("ace893fb2c9553a38a873fb03d0e21a406b351a1\x00Add the docs\n\x00\n")
+			return
+		case "merge-base":
+			if args[len(args)-1] == "xyz" {
+				os.Exit(1)
+			}
+			if args[2] != "--is-ancestor" {
+				fmt.Println("1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5")
+			}
+			return
+		case "diff":
+			fmt.Print("M\x00README.md\x00A\x00docs/with space.md\x00R087\x00old.go\x00new.go\x00D\x00gone.txt\x00T\x00link\x00")
+			return
 		case "rev-parse":
 			fmt.Print("302f5f5b48b9feee797a66c88811f1770bcb2dcf")
 			return
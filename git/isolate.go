@@ -0,0 +1,63 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// isolatedArgs turn off hooks and every protocol except the ones we clone with
+var isolatedArgs = []string{
+	"-c", "core.hooksPath=/dev/null",
+	"-c", "protocol.allow=never",
+	"-c", "protocol.https.allow=always",
+	"-c", "protocol.ssh.allow=always",
+}
+
+// isolated is set once Isolate has been called
+var isolated bool
+
+// IsolatedArgs are the options every Git command is run with once isolated
+func IsolatedArgs() []string {
+	return append([]string{}, isolatedArgs...)
+}
+
+// IsolatedEnv is the environment every Git command is run with once isolated,
+// in an empty home
+func IsolatedEnv(home string) [][2]string {
+	return [][2]string{
+		{"HOME", home},
+		{"XDG_CONFIG_HOME", filepath.Join(home, ".config")},
+		{"GIT_CONFIG_NOSYSTEM", "1"},
+		// Git before 2.32 ignores this, but then only finds the empty HOME's config
+		{"GIT_CONFIG_GLOBAL", os.DevNull},
+		{"GIT_CONFIG_COUNT", "0"},
+		{"GIT_CONFIG_PARAMETERS", ""},
+		{"GIT_TERMINAL_PROMPT", "0"},
+	}
+}
+
+// Isolate runs every Git command after this without the system or global Git
+// config, hooks, prompts or stdin, in an empty HOME. The returned function
+// removes that HOME again.
+func Isolate() (func(), error) {
+	home, err := ioutil.TempDir("", "bookend-home")
+	if err != nil {
+		return nil, err
+	}
+
+	// gpg and ssh still need the real HOME to find their keys and config
+	if osGetEnv("GNUPGHOME") == "" && osGetEnv("HOME") != "" {
+		Setenv("GNUPGHOME", filepath.Join(osGetEnv("HOME"), ".gnupg"))
+	}
+	if osGetEnv("GIT_SSH_COMMAND") == "" && osGetEnv("GIT_SSH") == "" {
+		Setenv("GIT_SSH_COMMAND", "ssh -o BatchMode=yes")
+	}
+
+	for _, env := range IsolatedEnv(home) {
+		Setenv(env[0], env[1])
+	}
+	isolated = true
+
+	return func() { os.RemoveAll(home) }, nil
+}
@@ -0,0 +1,84 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsolate(t *testing.T) {
+	env := map[string]string{"GIT_PATH": "/bin/git", "HOME": "/home/sd"}
+	osGetEnv = func(variable string) string { return env[variable] }
+
+	var args []string
+	execCommand = getFakeExecCommand(func(command string, arguments ...string) {
+		args = arguments
+	})
+	defer func() {
+		execCommand = exec.Command
+		extraEnv = nil
+		isolated = false
+	}()
+
+	cleanup, err := Isolate()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	home := ""
+	for _, value := range extraEnv {
+		if strings.HasPrefix(value, "HOME=") {
+			home = strings.TrimPrefix(value, "HOME=")
+		}
+	}
+	if _, err := os.Stat(home); err != nil {
+		t.Errorf("Expected an isolated HOME, got %v", err)
+	}
+
+	out, err := ExecuteReturn("env")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	for _, want := range []string{
+		"HOME=" + home,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_TERMINAL_PROMPT=0",
+		"GNUPGHOME=/home/sd/.gnupg",
+		"GIT_SSH_COMMAND=ssh -o BatchMode=yes",
+	} {
+		if !strings.Contains(out+"\n", want+"\n") {
+			t.Errorf("Expected %s in the environment, got %q", want, out)
+		}
+	}
+	want := append(append([]string{}, isolatedArgs...), "env")
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Received the wrong arguments: %v, want %v", args, want)
+	}
+
+	cleanup()
+	if _, err := os.Stat(home); !os.IsNotExist(err) {
+		t.Errorf("Expected the isolated HOME to be removed, got %v", err)
+	}
+}
+
+func TestIsolateKeepsGnupgHome(t *testing.T) {
+	env := map[string]string{"GIT_PATH": "/bin/git", "HOME": "/home/sd", "GNUPGHOME": "/keys", "GIT_SSH": "plink"}
+	osGetEnv = func(variable string) string { return env[variable] }
+	defer func() {
+		extraEnv = nil
+		isolated = false
+	}()
+
+	cleanup, err := Isolate()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer cleanup()
+
+	for _, value := range extraEnv {
+		if strings.HasPrefix(value, "GNUPGHOME=") || strings.HasPrefix(value, "GIT_SSH_COMMAND=") {
+			t.Errorf("Expected %s to be left alone", value)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// nativeVersion is reported instead of the Git version when running in-process
+const nativeVersion = "go-git v5 (native)"
+
+// native is set once UseNative has been called
+var native bool
+
+// nativeProgress is where go-git writes the progress it gets from the remote
+var nativeProgress io.Writer = os.Stderr
+
+// errNotAncestor is merge-base --is-ancestor's exit code 1
+var errNotAncestor = errors.New("not an ancestor")
+
+// UseNative runs the Git commands that go-git supports in-process, so that
+// checkouts work without GIT_PATH. Anything else (like merges) still uses it.
+func UseNative() {
+	native = true
+}
+
+// nativeCommands are the commands we can run in-process, * matches any argument
+var nativeCommands = []struct {
+	args []string
+	run  func(args []string) (string, error)
+}{
+	{[]string{"clone", "--quiet", "--progress", "--branch", "*", "*", "*"}, func(a []string) (string, error) {
+		return "", nativeClone(a[4], a[5], a[6])
+	}},
+	{[]string{"fetch", "--progress", "origin", "*:*"}, func(a []string) (string, error) { return "", nativeFetch(a[3]) }},
+	{[]string{"reset", "--hard", "*"}, func(a []string) (string, error) { return nativeReset(a[2]) }},
+	{[]string{"config", "user.name", "*"}, func(a []string) (string, error) {
+		return "", nativeConfig(func(c *config.Config) { c.User.Name = a[2] })
+	}},
+	{[]string{"config", "user.email", "*"}, func(a []string) (string, error) {
+		return "", nativeConfig(func(c *config.Config) { c.User.Email = a[2] })
+	}},
+	{[]string{"rev-parse", "HEAD"}, func(a []string) (string, error) { return nativeRevParse("HEAD") }},
+	{[]string{"rev-parse", "--verify", "*^{commit}"}, func(a []string) (string, error) {
+		return nativeRevParse(strings.TrimSuffix(a[2], "^{commit}"))
+	}},
+	{[]string{"merge-base", "--is-ancestor", "*", "*"}, func(a []string) (string, error) {
+		return "", nativeIsAncestor(a[2], a[3])
+	}},
+	{[]string{"ls-remote", "--exit-code", "--heads", "*", "*"}, func(a []string) (string, error) {
+		return nativeListBranch(a[3], a[4])
+	}},
+}
+
+// matches checks arguments against a pattern, where * stands for anything
+// (including an empty string) and *:* or *^{commit} for the same with that in it
+func matches(arguments, pattern []string) bool {
+	if len(arguments) != len(pattern) {
+		return false
+	}
+	for i, want := range pattern {
+		switch {
+		case want == "*":
+		case strings.HasPrefix(want, "*"):
+			if !strings.Contains(arguments[i], strings.Trim(want, "*")) {
+				return false
+			}
+		case want != arguments[i]:
+			return false
+		}
+	}
+	return true
+}
+
+// nativeCommand runs arguments in-process if we can, returning what Git would
+// have printed to stdout. ok is false if Git has to run them instead.
+func nativeCommand(arguments []string) (output string, ok bool, err error) {
+	if !native {
+		return "", false, nil
+	}
+	for _, command := range nativeCommands {
+		if matches(arguments, command.args) {
+			if nativeContext.Err() != nil {
+				return "", true, errInterrupted
+			}
+			output, err = command.run(arguments)
+			return output, true, err
+		}
+	}
+	return "", false, nil
+}
+
+func nativeClone(branch, url, dir string) error {
+	if isolated {
+		endpoint, err := transport.NewEndpoint(url)
+		if err != nil {
+			return err
+		}
+		if endpoint.Protocol != "https" && endpoint.Protocol != "ssh" {
+			return fmt.Errorf("transport '%s' not allowed", endpoint.Protocol)
+		}
+	}
+	_, err := gogit.PlainCloneContext(nativeContext, dir, false, &gogit.CloneOptions{
+		URL:           url,
+		ReferenceName: plumbing.NewBranchReferenceName(branch),
+		Progress:      nativeProgress,
+	})
+	return err
+}
+
+// nativeFetch fetches a refspec the way Git understands it on the command-line,
+// pull/1/head:pr is refs/pull/1/head:refs/heads/pr
+func nativeFetch(spec string) error {
+	repo, err := gogit.PlainOpen(".")
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(strings.TrimPrefix(spec, "+"), ":", 2)
+	src, dst := parts[0], parts[1]
+	if !strings.HasPrefix(src, "refs/") {
+		if strings.Contains(src, "/") {
+			src = "refs/" + src
+		} else {
+			src = "refs/heads/" + src
+		}
+	}
+	if !strings.HasPrefix(dst, "refs/") {
+		dst = "refs/heads/" + dst
+	}
+
+	err = repo.FetchContext(nativeContext, &gogit.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{config.RefSpec("+" + src + ":" + dst)},
+		Progress:   nativeProgress,
+	})
+	if err == gogit.NoErrAlreadyUpToDate {
+		return nil
+	}
+	return err
+}
+
+func nativeReset(revision string) (string, error) {
+	repo, err := gogit.PlainOpen(".")
+	if err != nil {
+		return "", err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", revision, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", revision, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	if err = worktree.Reset(&gogit.ResetOptions{Commit: *hash, Mode: gogit.HardReset}); err != nil {
+		return "", err
+	}
+	subject := strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+	return fmt.Sprintf("HEAD is now at %s %s\n", hash.String()[:7], subject), nil
+}
+
+func nativeConfig(set func(*config.Config)) error {
+	repo, err := gogit.PlainOpen(".")
+	if err != nil {
+		return err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	set(cfg)
+	return repo.SetConfig(cfg)
+}
+
+func nativeRevParse(revision string) (string, error) {
+	repo, err := gogit.PlainOpen(".")
+	if err != nil {
+		return "", err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return "", err
+	}
+	return hash.String() + "\n", nil
+}
+
+func nativeIsAncestor(ancestor, descendant string) error {
+	repo, err := gogit.PlainOpen(".")
+	if err != nil {
+		return err
+	}
+	commits := []*object.Commit{}
+	for _, revision := range []string{ancestor, descendant} {
+		hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+		if err != nil {
+			return fmt.Errorf("%s: %v", revision, err)
+		}
+		commit, err := repo.CommitObject(*hash)
+		if err != nil {
+			return fmt.Errorf("%s: %v", revision, err)
+		}
+		commits = append(commits, commit)
+	}
+	isAncestor, err := commits[0].IsAncestor(commits[1])
+	if err == nil && !isAncestor {
+		return errNotAncestor
+	}
+	return err
+}
+
+func nativeListBranch(url, branch string) (string, error) {
+	remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{url}})
+	refs, err := remote.ListContext(nativeContext, &gogit.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.NewBranchReferenceName(branch) {
+			return fmt.Sprintf("%s\t%s\n", ref.Hash(), ref.Name()), nil
+		}
+	}
+	return "", fmt.Errorf("couldn't find remote ref %q", plumbing.NewBranchReferenceName(branch))
+}
@@ -0,0 +1,159 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+// originRepo makes a repository with two commits on master, and a pull request
+// from the first one
+func originRepo(dir string, t *testing.T) (first, second, pr string) {
+	repo, err := gogit.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(file string) plumbing.Hash {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add(file); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := worktree.Commit("Add "+file, &gogit.CommitOptions{
+			Author: &object.Signature{Name: "stjohn", Email: "stjohn@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	base := commit("README.md")
+	if err = worktree.Checkout(&gogit.CheckoutOptions{Branch: "refs/heads/feature", Create: true}); err != nil {
+		t.Fatal(err)
+	}
+	feature := commit("feature.md")
+	if err = repo.Storer.SetReference(plumbing.NewHashReference("refs/pull/1/head", feature)); err != nil {
+		t.Fatal(err)
+	}
+	if err = worktree.Checkout(&gogit.CheckoutOptions{Branch: "refs/heads/master"}); err != nil {
+		t.Fatal(err)
+	}
+	return base.String(), commit("CHANGELOG.md").String(), feature.String()
+}
+
+func TestNative(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first, second, pr := originRepo(filepath.Join(dir, "origin"), t)
+	url := "file://" + filepath.Join(dir, "origin", ".git")
+
+	// Serve file:// in-process too, rather than with git-upload-pack
+	client.InstallProtocol("file", server.DefaultServer)
+	cwd, _ := os.Getwd()
+	UseNative()
+	defer func() {
+		native = false
+		os.Chdir(cwd)
+	}()
+
+	os.Chdir(dir)
+	if err = ExecuteStream("clone", "--quiet", "--progress", "--branch", "master", url, "checkout"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	os.Chdir(filepath.Join(dir, "checkout"))
+
+	if err = ExecuteStream("config", "user.name", "sd-buildbot"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if sha, err := GetGitSha(); err != nil || sha != second {
+		t.Errorf("Received the wrong HEAD: %v (%v), want %v", sha, err, second)
+	}
+
+	if err = ExecuteStream("fetch", "--progress", "origin", "pull/1/head:pr"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if sha, err := GetGitRevision("pr"); err != nil || sha != pr {
+		t.Errorf("Received the wrong pull request: %v (%v), want %v", sha, err, pr)
+	}
+	if ok, err := IsAncestor(first, "HEAD"); err != nil || !ok {
+		t.Errorf("Expected %v to be on master (%v)", first, err)
+	}
+	if ok, err := IsAncestor(pr, "HEAD"); err != nil || ok {
+		t.Errorf("Expected %v not to be on master (%v)", pr, err)
+	}
+
+	if err = ExecuteStream("reset", "--hard", first); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if sha, err := GetGitSha(); err != nil || sha != first {
+		t.Errorf("Received the wrong HEAD: %v (%v), want %v", sha, err, first)
+	}
+	if _, err := os.Stat("CHANGELOG.md"); !os.IsNotExist(err) {
+		t.Errorf("Expected the worktree to be reset, got %v", err)
+	}
+
+	err = ExecuteStream("reset", "--hard", strings.Repeat("a", 40))
+	if Classify(err) != FailureNotFound {
+		t.Errorf("Received the wrong failure for '%v': %v, want %v", err, Classify(err), FailureNotFound)
+	}
+
+	out, err := ExecuteReturn("ls-remote", "--exit-code", "--heads", url, "master")
+	if err != nil || out != second+"\trefs/heads/master\n" {
+		t.Errorf("Received the wrong branch: %q (%v)", out, err)
+	}
+	err = ExecuteStream("ls-remote", "--exit-code", "--heads", url, "missing")
+	if Classify(err) != FailureNotFound {
+		t.Errorf("Received the wrong failure for '%v': %v, want %v", err, Classify(err), FailureNotFound)
+	}
+}
+
+func TestNativeFallsBack(t *testing.T) {
+	UseNative()
+	defer func() { native = false }()
+
+	for _, args := range [][]string{
+		{"merge", "--no-edit", "ace893fb2c9553a38a873fb03d0e21a406b351a1"},
+		{"fetch", "origin"},
+		{"reset", "--soft", "HEAD~1"},
+		{"config", "--get", "user.name"},
+	} {
+		if _, ok, _ := nativeCommand(args); ok {
+			t.Errorf("Expected %v to be run by Git", args)
+		}
+	}
+	if version, _ := GetGitVersion(); version.String() != nativeVersion {
+		t.Errorf("Received the wrong version: %v, want %v", version, nativeVersion)
+	}
+}
+
+func TestNativeIsolated(t *testing.T) {
+	UseNative()
+	isolated = true
+	defer func() {
+		native = false
+		isolated = false
+	}()
+
+	err := ExecuteStream("clone", "--quiet", "--progress", "--branch", "master", "file:///tmp/origin", "/tmp/foo")
+	if err == nil || err.Error() != "Command failed: transport 'file' not allowed" {
+		t.Errorf("Expected file:// to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package git
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	isatty "github.com/mattn/go-isatty"
+)
+
+// Ways of showing Git's progress
+const (
+	// ProgressAuto is ProgressTTY on a terminal and ProgressPlain otherwise
+	ProgressAuto = "auto"
+	// ProgressTTY keeps rewriting a single line, a few times a second
+	ProgressTTY = "tty"
+	// ProgressPlain prints a line at every 25% and when a phase is done
+	ProgressPlain = "plain"
+	// ProgressJSON prints a JSON ProgressEvent per line whenever the percentage changes
+	ProgressJSON = "json"
+	// ProgressRaw passes Git's output through untouched
+	ProgressRaw = "raw"
+)
+
+// ttyInterval is the least time between updates to the progress line
+const ttyInterval = 200 * time.Millisecond
+
+var progressMode = ProgressAuto
+var progressNow = time.Now
+var stderrIsTerminal = func() bool {
+	return isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd())
+}
+
+// received is how many bytes the last streamed command downloaded (if Git said)
+var received int64
+
+// SetProgress picks how the progress of every command run with ExecuteStream
+// after this is shown
+func SetProgress(mode string) {
+	progressMode = mode
+}
+
+// BytesReceived returns how much the last command run with ExecuteStream
+// downloaded, or 0 if it didn't show its progress
+func BytesReceived() int64 {
+	return received
+}
+
+// ProgressEvent is a single progress line from Git, like
+// "Receiving objects:  45% (2072/4605), 1.01 MiB | 1.01 MiB/s"
+type ProgressEvent struct {
+	Phase   string `json:"phase"`
+	Remote  bool   `json:"remote,omitempty"`
+	Percent int    `json:"percent"`
+	Current int64  `json:"current"`
+	// Total is 0 when Git is only counting, e.g. "Counting objects: 4605"
+	Total int64 `json:"total,omitempty"`
+	Bytes int64 `json:"bytes,omitempty"`
+	Rate  int64 `json:"bytes_per_second,omitempty"`
+	Done  bool  `json:"done"`
+	// Line is what Git printed
+	Line string `json:"-"`
+}
+
+var progressPattern = regexp.MustCompile(`^(remote: )?([A-Z][A-Za-z ]*): +` +
+	`(?:(\d+)% \((\d+)/(\d+)\)|(\d+))` +
+	`(?:, ([0-9.]+ (?:bytes?|KiB|MiB|GiB)))?` +
+	`(?: \| ([0-9.]+ (?:bytes?|KiB|MiB|GiB))/s)?` +
+	`(, done\.?)?\s*$`)
+
+var units = map[string]float64{
+	"byte":  1,
+	"bytes": 1,
+	"KiB":   1 << 10,
+	"MiB":   1 << 20,
+	"GiB":   1 << 30,
+}
+
+// parseSize reads a size the way Git prints it, e.g. 1.33 MiB is 1394606 bytes
+func parseSize(size string) int64 {
+	var value float64
+	var unit string
+	if _, err := fmt.Sscanf(size, "%g %s", &value, &unit); err != nil {
+		return 0
+	}
+	return int64(value * units[unit])
+}
+
+// ParseProgress reads a line of Git's progress output, ok is false for
+// anything else (like errors or the list of fetched refs)
+func ParseProgress(line string) (event ProgressEvent, ok bool) {
+	match := progressPattern.FindStringSubmatch(line)
+	if match == nil {
+		return event, false
+	}
+	event = ProgressEvent{
+		Phase:  match[2],
+		Remote: match[1] != "",
+		Bytes:  parseSize(match[7]),
+		Rate:   parseSize(match[8]),
+		Done:   match[9] != "",
+		Line:   strings.TrimSpace(line),
+	}
+	if match[3] != "" {
+		event.Percent, _ = strconv.Atoi(match[3])
+		event.Current, _ = strconv.ParseInt(match[4], 10, 64)
+		event.Total, _ = strconv.ParseInt(match[5], 10, 64)
+	} else {
+		event.Current, _ = strconv.ParseInt(match[6], 10, 64)
+		if event.Done {
+			event.Percent = 100
+		}
+	}
+	return event, true
+}
+
+// progressWriter sits between Git and stderr, turning the progress lines
+// (which Git rewrites with carriage returns) into output for mode
+type progressWriter struct {
+	out     io.Writer
+	mode    string
+	partial []byte
+	// open is set while the TTY progress line hasn't been ended with a newline
+	open     bool
+	last     ProgressEvent
+	shown    time.Time
+	received int64
+}
+
+func newProgressWriter(out io.Writer, mode string) *progressWriter {
+	if mode == ProgressAuto || mode == "" {
+		mode = ProgressPlain
+		if stderrIsTerminal() {
+			mode = ProgressTTY
+		}
+	}
+	return &progressWriter{out: out, mode: mode}
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	p.partial = append(p.partial, data...)
+	p.scan(p.mode != ProgressRaw)
+	if p.mode == ProgressRaw {
+		return p.out.Write(data)
+	}
+	return len(data), nil
+}
+
+// scan handles every complete line that has been written so far, printing
+// them if show is set
+func (p *progressWriter) scan(show bool) {
+	for {
+		end := bytes.IndexAny(p.partial, "\r\n")
+		if end == -1 {
+			return
+		}
+		line := string(p.partial[:end])
+		p.partial = p.partial[end+1:]
+		if line == "" {
+			continue
+		}
+		p.line(line, show)
+	}
+}
+
+// Close prints anything left over that didn't end in a newline
+func (p *progressWriter) Close() error {
+	if len(p.partial) > 0 {
+		line := string(p.partial)
+		p.partial = nil
+		p.line(line, p.mode != ProgressRaw)
+	}
+	if p.open {
+		p.open = false
+		fmt.Fprintln(p.out)
+	}
+	return nil
+}
+
+func (p *progressWriter) line(line string, show bool) {
+	event, ok := ParseProgress(line)
+	if ok && event.Phase == "Receiving objects" && event.Bytes > 0 {
+		p.received = event.Bytes
+	}
+	if !show {
+		return
+	}
+	if !ok {
+		if p.open {
+			p.open = false
+			fmt.Fprintln(p.out)
+		}
+		fmt.Fprintln(p.out, line)
+		return
+	}
+	p.event(event)
+}
+
+func (p *progressWriter) event(event ProgressEvent) {
+	newPhase := event.Phase != p.last.Phase || event.Remote != p.last.Remote
+	changed := newPhase || event.Percent != p.last.Percent || event.Done != p.last.Done
+
+	switch p.mode {
+	case ProgressTTY:
+		now := progressNow()
+		if !newPhase && !event.Done && now.Sub(p.shown) < ttyInterval {
+			break
+		}
+		if newPhase && p.open {
+			fmt.Fprintln(p.out)
+		}
+		fmt.Fprintf(p.out, "\r%s\x1b[K", event.Line)
+		p.open, p.shown = true, now
+		if event.Done {
+			fmt.Fprintln(p.out)
+			p.open = false
+		}
+	case ProgressPlain:
+		// Git always follows 100% with a done line, so that's the one we show
+		milestone := event.Percent/25 != p.last.Percent/25 || newPhase
+		if event.Done || (newPhase && event.Total == 0) || (milestone && event.Total > 0 && event.Percent < 100) {
+			fmt.Fprintln(p.out, event.Line)
+		}
+	case ProgressJSON:
+		if changed {
+			line, _ := json.Marshal(event)
+			fmt.Fprintf(p.out, "%s\n", line)
+		}
+	}
+	p.last = event
+}
@@ -0,0 +1,187 @@
+package git
+
+import (
+	"bytes"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// cloneOutput is what git clone --progress writes to stderr, in the pieces it arrives in
+var cloneOutput = []string{
+	"Cloning into '/tmp/foo'...\n",
+	"remote: Enumerating objects: 4605, done.\n",
+	"remote: Counting objects:   0% (1/54)\rremote: Counting objects: 100% (54/54)\r",
+	"remote: Counting objects: 100% (54/54), done.\n",
+	"remote: Total 4605 (delta 26), reused 24 (delta 11), pack-reused 4540\n",
+	"Receiving objects:   0% (1/4605)\rReceiving objects:  10% (461/4605)\r",
+	"Receiving objects:  45% (2073/4605), 612.00 KiB | 1.01 MiB/s\r",
+	"Receiving objects:  80% (3684/46",
+	"05), 1.10 MiB | 1.01 MiB/s\r",
+	"Receiving objects: 100% (4605/4605), 1.33 MiB | 1.01 MiB/s, done.\n",
+	"Resolving deltas: 100% (2846/2846), done.\n",
+}
+
+func TestParseProgress(t *testing.T) {
+	tests := map[string]ProgressEvent{
+		"Receiving objects:  45% (2073/4605), 1.01 MiB | 512.00 KiB/s": {
+			Phase: "Receiving objects", Percent: 45, Current: 2073, Total: 4605, Bytes: 1059061, Rate: 524288,
+		},
+		"Receiving objects: 100% (3/3), 230 bytes | 1 byte/s, done.": {
+			Phase: "Receiving objects", Percent: 100, Current: 3, Total: 3, Bytes: 230, Rate: 1, Done: true,
+		},
+		"remote: Compressing objects: 100% (54/54), done.        ": {
+			Phase: "Compressing objects", Remote: true, Percent: 100, Current: 54, Total: 54, Done: true,
+		},
+		"remote: Counting objects: 4605": {
+			Phase: "Counting objects", Remote: true, Current: 4605,
+		},
+		"Enumerating objects: 5, done.": {
+			Phase: "Enumerating objects", Percent: 100, Current: 5, Done: true,
+		},
+		"Receiving objects: 100% (9/9), 2.00 GiB | 100.00 MiB/s, done.": {
+			Phase: "Receiving objects", Percent: 100, Current: 9, Total: 9, Bytes: 2147483648, Rate: 104857600, Done: true,
+		},
+	}
+	for line, want := range tests {
+		got, ok := ParseProgress(line)
+		got.Line = ""
+		if !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseProgress(%q) = %+v, %v, want %+v", line, got, ok, want)
+		}
+	}
+
+	for _, line := range []string{
+		"",
+		"Cloning into '/tmp/foo'...",
+		"remote: Total 4605 (delta 26), reused 24 (delta 11), pack-reused 4540",
+		" * [new ref]         refs/pull/692/head -> pr",
+		"fatal: Authentication failed for 'https://github.com/testOrg/testRepo.git/'",
+	} {
+		if event, ok := ParseProgress(line); ok {
+			t.Errorf("Expected %q not to be progress, got %+v", line, event)
+		}
+	}
+}
+
+func writeProgress(mode string) (string, int64) {
+	var out bytes.Buffer
+	p := newProgressWriter(&out, mode)
+	for _, chunk := range cloneOutput {
+		p.Write([]byte(chunk))
+	}
+	p.Close()
+	return out.String(), p.received
+}
+
+func TestProgressPlain(t *testing.T) {
+	got, received := writeProgress(ProgressPlain)
+	want := "Cloning into '/tmp/foo'...\n" +
+		"remote: Enumerating objects: 4605, done.\n" +
+		"remote: Counting objects:   0% (1/54)\n" +
+		"remote: Counting objects: 100% (54/54), done.\n" +
+		"remote: Total 4605 (delta 26), reused 24 (delta 11), pack-reused 4540\n" +
+		"Receiving objects:   0% (1/4605)\n" +
+		"Receiving objects:  45% (2073/4605), 612.00 KiB | 1.01 MiB/s\n" +
+		"Receiving objects:  80% (3684/4605), 1.10 MiB | 1.01 MiB/s\n" +
+		"Receiving objects: 100% (4605/4605), 1.33 MiB | 1.01 MiB/s, done.\n" +
+		"Resolving deltas: 100% (2846/2846), done.\n"
+	if got != want {
+		t.Errorf("Received the wrong output:\n%q\nwant:\n%q", got, want)
+	}
+	if received != 1394606 {
+		t.Errorf("Received the wrong number of bytes: %v, want %v", received, 1394606)
+	}
+}
+
+func TestProgressTTY(t *testing.T) {
+	start := time.Date(2017, 8, 1, 10, 0, 0, 0, time.UTC)
+	calls := 0
+	progressNow = func() time.Time {
+		calls++
+		return start.Add(time.Duration(calls) * 100 * time.Millisecond)
+	}
+	defer func() { progressNow = time.Now }()
+
+	got, _ := writeProgress(ProgressTTY)
+	want := "Cloning into '/tmp/foo'...\n" +
+		"\rremote: Enumerating objects: 4605, done.\x1b[K\n" +
+		"\rremote: Counting objects:   0% (1/54)\x1b[K" +
+		"\rremote: Counting objects: 100% (54/54), done.\x1b[K\n" +
+		"remote: Total 4605 (delta 26), reused 24 (delta 11), pack-reused 4540\n" +
+		"\rReceiving objects:   0% (1/4605)\x1b[K" +
+		"\rReceiving objects:  45% (2073/4605), 612.00 KiB | 1.01 MiB/s\x1b[K" +
+		"\rReceiving objects: 100% (4605/4605), 1.33 MiB | 1.01 MiB/s, done.\x1b[K\n" +
+		"\rResolving deltas: 100% (2846/2846), done.\x1b[K\n"
+	if got != want {
+		t.Errorf("Received the wrong output:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestProgressJSON(t *testing.T) {
+	got, _ := writeProgress(ProgressJSON)
+	want := "Cloning into '/tmp/foo'...\n" +
+		`{"phase":"Enumerating objects","remote":true,"percent":100,"current":4605,"done":true}` + "\n" +
+		`{"phase":"Counting objects","remote":true,"percent":0,"current":1,"total":54,"done":false}` + "\n" +
+		`{"phase":"Counting objects","remote":true,"percent":100,"current":54,"total":54,"done":false}` + "\n" +
+		`{"phase":"Counting objects","remote":true,"percent":100,"current":54,"total":54,"done":true}` + "\n" +
+		"remote: Total 4605 (delta 26), reused 24 (delta 11), pack-reused 4540\n" +
+		`{"phase":"Receiving objects","percent":0,"current":1,"total":4605,"done":false}` + "\n" +
+		`{"phase":"Receiving objects","percent":10,"current":461,"total":4605,"done":false}` + "\n" +
+		`{"phase":"Receiving objects","percent":45,"current":2073,"total":4605,"bytes":626688,"bytes_per_second":1059061,"done":false}` + "\n" +
+		`{"phase":"Receiving objects","percent":80,"current":3684,"total":4605,"bytes":1153433,"bytes_per_second":1059061,"done":false}` + "\n" +
+		`{"phase":"Receiving objects","percent":100,"current":4605,"total":4605,"bytes":1394606,"bytes_per_second":1059061,"done":true}` + "\n" +
+		`{"phase":"Resolving deltas","percent":100,"current":2846,"total":2846,"done":true}` + "\n"
+	if got != want {
+		t.Errorf("Received the wrong output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestProgressRaw(t *testing.T) {
+	got, received := writeProgress(ProgressRaw)
+	want := ""
+	for _, chunk := range cloneOutput {
+		want += chunk
+	}
+	if got != want {
+		t.Errorf("Received the wrong output:\n%q\nwant:\n%q", got, want)
+	}
+	if received != 1394606 {
+		t.Errorf("Received the wrong number of bytes: %v, want %v", received, 1394606)
+	}
+}
+
+func TestProgressAuto(t *testing.T) {
+	defer func(isTerminal func() bool) { stderrIsTerminal = isTerminal }(stderrIsTerminal)
+
+	stderrIsTerminal = func() bool { return true }
+	if mode := newProgressWriter(nil, ProgressAuto).mode; mode != ProgressTTY {
+		t.Errorf("Received the wrong mode on a terminal: %v, want %v", mode, ProgressTTY)
+	}
+	stderrIsTerminal = func() bool { return false }
+	if mode := newProgressWriter(nil, ProgressAuto).mode; mode != ProgressPlain {
+		t.Errorf("Received the wrong mode without a terminal: %v, want %v", mode, ProgressPlain)
+	}
+}
+
+func TestBytesReceived(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	if err := ExecuteStream("clone"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if BytesReceived() != 1572864 {
+		t.Errorf("Received the wrong number of bytes: %v, want %v", BytesReceived(), 1572864)
+	}
+
+	if err := ExecuteStream("foo", "bar"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if BytesReceived() != 0 {
+		t.Errorf("Expected the count to be reset, got %v", BytesReceived())
+	}
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// errInterrupted is returned for commands started after Interrupt
+var errInterrupted = errors.New("interrupted")
+
+// nativeContext is cancelled by Interrupt, to stop commands run in-process
+var nativeContext, cancelNative = context.WithCancel(context.Background())
+
+// running are the Git commands in progress (more than one when run with
+// ExecuteIn), so that signals can be passed on
+var running struct {
+	sync.Mutex
+	processes   map[*os.Process]bool
+	interrupted bool
+}
+
+func start(cmd *exec.Cmd) error {
+	running.Lock()
+	defer running.Unlock()
+	if running.interrupted {
+		return errInterrupted
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if running.processes == nil {
+		running.processes = map[*os.Process]bool{}
+	}
+	running.processes[cmd.Process] = true
+	return nil
+}
+
+func wait(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	running.Lock()
+	delete(running.processes, cmd.Process)
+	running.Unlock()
+	return err
+}
+
+// Interrupt passes sig on to the Git commands that are running (if any) and
+// stops any more from starting. The running commands still return normally
+// once they have exited.
+func Interrupt(sig os.Signal) error {
+	running.Lock()
+	defer running.Unlock()
+	running.interrupted = true
+	cancelNative()
+
+	var err error
+	for process := range running.processes {
+		if signalErr := process.Signal(sig); signalErr != nil {
+			err = signalErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestInterrupt(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = fakeExecCommand
+	defer func() {
+		execCommand = exec.Command
+		running.interrupted = false
+		nativeContext, cancelNative = context.WithCancel(context.Background())
+	}()
+
+	go func() {
+		for {
+			running.Lock()
+			started := len(running.processes) > 0
+			running.Unlock()
+			if started {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err := Interrupt(os.Interrupt); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}()
+
+	began := time.Now()
+	err := ExecuteStream("sleep")
+	if err == nil || time.Since(began) > 5*time.Second {
+		t.Errorf("Expected the command to be interrupted, got %v", err)
+	}
+
+	err = ExecuteStream("foo")
+	if err == nil || err.Error() != "Command wouldn't start: interrupted" {
+		t.Errorf("Expected no more commands to start, got %v", err)
+	}
+}
+
+func TestInterruptAll(t *testing.T) {
+	osGetEnv = func(variable string) (out string) { return "/bin/git" }
+
+	execCommand = fakeExecCommand
+	defer func() {
+		execCommand = exec.Command
+		running.interrupted = false
+		nativeContext, cancelNative = context.WithCancel(context.Background())
+	}()
+
+	go func() {
+		for {
+			running.Lock()
+			started := len(running.processes) == 2
+			running.Unlock()
+			if started {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err := Interrupt(os.Interrupt); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}()
+
+	began := time.Now()
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := ExecuteIn("", "sleep")
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == nil {
+			t.Errorf("Expected every command to be interrupted")
+		}
+	}
+	if time.Since(began) > 5*time.Second {
+		t.Errorf("Expected the commands to be interrupted, took %v", time.Since(began))
+	}
+}
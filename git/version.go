@@ -0,0 +1,106 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a Git version, like 2.39.5
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// Name is how Git describes itself, e.g. v2.39.5 (Apple Git-143)
+	Name string
+}
+
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseVersion reads a version like 2.39.5, v2.39 or 2.39.5.windows.1
+func ParseVersion(version string) (Version, error) {
+	match := versionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return Version{}, fmt.Errorf("%q isn't a version", version)
+	}
+	parsed := Version{Name: "v" + strings.TrimPrefix(version, "v")}
+	parsed.Major, _ = strconv.Atoi(match[1])
+	parsed.Minor, _ = strconv.Atoi(match[2])
+	parsed.Patch, _ = strconv.Atoi(match[3])
+	return parsed, nil
+}
+
+func (v Version) String() string {
+	return v.Name
+}
+
+// Known is false when we couldn't tell which version of Git this is
+func (v Version) Known() bool {
+	return v.Major > 0
+}
+
+// AtLeast checks whether v is min or newer
+func (v Version) AtLeast(min Version) bool {
+	if v.Major != min.Major {
+		return v.Major > min.Major
+	}
+	if v.Minor != min.Minor {
+		return v.Minor > min.Minor
+	}
+	return v.Patch >= min.Patch
+}
+
+// Capability is something only newer versions of Git can do
+type Capability string
+
+// Capabilities we check for before using them
+const (
+	AtomicPush    Capability = "atomic-push"
+	ProtocolAllow Capability = "protocol-allow"
+	ProtocolV2    Capability = "protocol-v2"
+	PartialClone  Capability = "partial-clone"
+	SparseCone    Capability = "sparse-cone"
+	SHA256        Capability = "sha256"
+	SSHSignatures Capability = "ssh-signatures"
+)
+
+// capabilities are the versions of Git that each capability arrived in
+var capabilities = []struct {
+	capability Capability
+	since      Version
+}{
+	{AtomicPush, Version{2, 4, 0, "v2.4.0"}},
+	{ProtocolAllow, Version{2, 12, 0, "v2.12.0"}},
+	{ProtocolV2, Version{2, 18, 0, "v2.18.0"}},
+	{PartialClone, Version{2, 19, 0, "v2.19.0"}},
+	{SparseCone, Version{2, 25, 0, "v2.25.0"}},
+	{SHA256, Version{2, 29, 0, "v2.29.0"}},
+	{SSHSignatures, Version{2, 34, 0, "v2.34.0"}},
+}
+
+// Since is the first version of Git with c
+func (c Capability) Since() Version {
+	for _, known := range capabilities {
+		if known.capability == c {
+			return known.since
+		}
+	}
+	return Version{}
+}
+
+// Supports checks whether this version of Git can do c
+func (v Version) Supports(c Capability) bool {
+	return v.Known() && v.AtLeast(c.Since())
+}
+
+// Capabilities lists everything this version of Git can do
+func (v Version) Capabilities() []Capability {
+	supported := []Capability{}
+	for _, known := range capabilities {
+		if v.Supports(known.capability) {
+			supported = append(supported, known.capability)
+		}
+	}
+	return supported
+}
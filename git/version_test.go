@@ -0,0 +1,60 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string]Version{
+		"2.39.5":                 {2, 39, 5, "v2.39.5"},
+		"v2.20":                  {2, 20, 0, "v2.20"},
+		"2.40.1.windows.1":       {2, 40, 1, "v2.40.1.windows.1"},
+		"2.39.5 (Apple Git-154)": {2, 39, 5, "v2.39.5 (Apple Git-154)"},
+		"2.45.0-rc1":             {2, 45, 0, "v2.45.0-rc1"},
+	}
+	for input, want := range tests {
+		if got, err := ParseVersion(input); err != nil || got != want {
+			t.Errorf("Received the wrong version for %q: %v (%v), want %v", input, got, err, want)
+		}
+	}
+
+	if _, err := ParseVersion("latest"); err == nil || err.Error() != `"latest" isn't a version` {
+		t.Errorf("Received the wrong error: %v", err)
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	min := Version{2, 20, 1, "v2.20.1"}
+	tests := map[Version]bool{
+		{1, 99, 0, ""}: false,
+		{2, 19, 9, ""}: false,
+		{2, 20, 0, ""}: false,
+		{2, 20, 1, ""}: true,
+		{2, 21, 0, ""}: true,
+		{3, 0, 0, ""}:  true,
+	}
+	for version, want := range tests {
+		if got := version.AtLeast(min); got != want {
+			t.Errorf("Received the wrong answer for %v >= %v: %v, want %v", version, min, got, want)
+		}
+	}
+}
+
+func TestVersionCapabilities(t *testing.T) {
+	tests := map[Version][]Capability{
+		{1, 8, 0, ""}:  {},
+		{2, 25, 1, ""}: {AtomicPush, ProtocolAllow, ProtocolV2, PartialClone, SparseCone},
+		{2, 39, 5, ""}: {AtomicPush, ProtocolAllow, ProtocolV2, PartialClone, SparseCone, SHA256, SSHSignatures},
+		{0, 0, 0, ""}:  {},
+	}
+	for version, want := range tests {
+		if got := version.Capabilities(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Received the wrong capabilities for %v: %v, want %v", version, got, want)
+		}
+	}
+
+	if !(Version{2, 34, 0, ""}).Supports(SSHSignatures) || (Version{2, 33, 9, ""}).Supports(SSHSignatures) {
+		t.Errorf("Expected SSH signatures from v2.34.0")
+	}
+}
@@ -0,0 +1,95 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Commit status states
+const (
+	StatePending = "pending"
+	StateSuccess = "success"
+	StateFailure = "failure"
+	StateError   = "error"
+)
+
+// maxDescription is the longest description GitHub accepts
+const maxDescription = 140
+
+// Status is a commit status, as shown next to the commit in GitHub
+type Status struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context,omitempty"`
+}
+
+// Client talks to the GitHub (or GitHub Enterprise) API
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// APIURL returns the API base URL for a GitHub host, GitHub Enterprise
+// serves it from /api/v3 on the same host
+func APIURL(host string) string {
+	if host == "github.com" {
+		return "https://api.github.com"
+	}
+	return fmt.Sprintf("https://%s/api/v3", host)
+}
+
+// NewClient returns a client for the API at baseURL
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SetStatus sets a commit status on sha in repo (owner/name), giving up
+// when ctx is cancelled
+func (c *Client) SetStatus(ctx context.Context, repo, sha string, status Status) error {
+	if description := []rune(status.Description); len(description) > maxDescription {
+		status.Description = string(description[:maxDescription-3]) + "..."
+	}
+	body, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/statuses/%s", c.BaseURL, repo, sha)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "token "+c.Token)
+	}
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("Unable to set commit status: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		contents, _ := ioutil.ReadAll(res.Body)
+		json.Unmarshal(contents, &apiErr)
+		return fmt.Errorf("Unable to set commit status: %s %s", res.Status, apiErr.Message)
+	}
+	return nil
+}
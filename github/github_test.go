@@ -0,0 +1,85 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAPIURL(t *testing.T) {
+	tests := map[string]string{
+		"github.com":           "https://api.github.com",
+		"ghe.example.com":      "https://ghe.example.com/api/v3",
+		"ghe.example.com:8443": "https://ghe.example.com:8443/api/v3",
+	}
+	for host, want := range tests {
+		if got := APIURL(host); got != want {
+			t.Errorf("APIURL(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	var got Status
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/v3/repos/testOrg/testRepo/statuses/302f5f5b48b9feee797a66c88811f1770bcb2dcf" {
+			t.Errorf("Received the wrong request: %v %v", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "token secret" {
+			t.Errorf("Received the wrong authorization: %v", auth)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/api/v3/", "secret")
+	err := client.SetStatus(context.Background(), "testOrg/testRepo", "302f5f5b48b9feee797a66c88811f1770bcb2dcf", Status{
+		State:       StateFailure,
+		Description: strings.Repeat("x", 200),
+		Context:     "bookend",
+	})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	want := Status{
+		State:       StateFailure,
+		Description: strings.Repeat("x", 137) + "...",
+		Context:     "bookend",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Received the wrong status: %+v, want %+v", got, want)
+	}
+}
+
+func TestSetStatusFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "Not Found"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "secret")
+	err := client.SetStatus(context.Background(), "testOrg/testRepo", "302f5f5b48b9feee797a66c88811f1770bcb2dcf", Status{State: StatePending})
+
+	want := "Unable to set commit status: 404 Not Found Not Found"
+	if err == nil || err.Error() != want {
+		t.Errorf("Received the wrong error: %v, want %v", err, want)
+	}
+}
+
+func TestSetStatusUnreachable(t *testing.T) {
+	client := NewClient("http://127.0.0.1:1", "")
+	err := client.SetStatus(context.Background(), "testOrg/testRepo", "302f5f5b48b9feee797a66c88811f1770bcb2dcf", Status{State: StatePending})
+
+	if err == nil || !strings.HasPrefix(err.Error(), "Unable to set commit status: ") {
+		t.Errorf("Received the wrong error: %v", err)
+	}
+}
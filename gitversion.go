@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+)
+
+// requiredCapabilities are what this run needs Git to be able to do
+func requiredCapabilities(args arguments.CommandArgs) []git.Capability {
+	required := []git.Capability{}
+	if len(args.SHA) == 64 {
+		required = append(required, git.SHA256)
+	}
+	if args.RequireSigned && args.AllowedSigners != "" {
+		required = append(required, git.SSHSignatures)
+	}
+	// Older Git would ignore the protocol.allow that limits it to HTTPS and SSH
+	if args.IsolateGit {
+		required = append(required, git.ProtocolAllow)
+	}
+	if args.Command == "teardown" {
+		required = append(required, git.AtomicPush)
+	}
+	return required
+}
+
+// useCapabilities picks the commands that suit this version of Git
+func useCapabilities(version git.Version) {
+	// Only the refs we ask for are sent, not every branch and tag (Git before
+	// 2.26 doesn't use protocol v2 unless asked to)
+	if version.Supports(git.ProtocolV2) {
+		gitSetConfig("protocol.version", "2")
+	}
+}
+
+// checkGitVersion makes sure Git is new enough for this run before we start,
+// versions we can't read are given the benefit of the doubt
+func checkGitVersion(version git.Version, args arguments.CommandArgs) error {
+	if !version.Known() {
+		return nil
+	}
+	if args.MinGitVersion != "" {
+		min, err := git.ParseVersion(args.MinGitVersion)
+		if err != nil {
+			return err
+		}
+		if !version.AtLeast(min) {
+			return fmt.Errorf("Git %s is older than --min-git-version %s", version, min)
+		}
+	}
+	for _, capability := range requiredCapabilities(args) {
+		if !version.Supports(capability) {
+			return fmt.Errorf("Git %s doesn't support %s, it needs %s or newer", version, capability, capability.Since())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+)
+
+func TestCheckGitVersion(t *testing.T) {
+	sha256 := "6f9a0c8e6d3c1b5e2f4a7d9c0b1e3f5a7c9d1e3f5a7b9c1d3e5f7a9b1c3d5e7f"
+	tests := []struct {
+		version git.Version
+		args    arguments.CommandArgs
+		want    string
+	}{
+		{git.Version{Major: 2, Minor: 20, Name: "v2.20.0"}, arguments.CommandArgs{MinGitVersion: "2.20"}, ""},
+		{git.Version{Major: 2, Minor: 19, Patch: 5, Name: "v2.19.5"}, arguments.CommandArgs{MinGitVersion: "2.20"},
+			"Git v2.19.5 is older than --min-git-version v2.20"},
+		{git.Version{Name: "vcustom.build"}, arguments.CommandArgs{MinGitVersion: "2.20", SHA: sha256}, ""},
+		{git.Version{Major: 2, Minor: 28, Name: "v2.28.0"}, arguments.CommandArgs{SHA: sha256},
+			"Git v2.28.0 doesn't support sha256, it needs v2.29.0 or newer"},
+		{git.Version{Major: 2, Minor: 30, Name: "v2.30.0"}, arguments.CommandArgs{RequireSigned: true, AllowedSigners: "signers"},
+			"Git v2.30.0 doesn't support ssh-signatures, it needs v2.34.0 or newer"},
+		{git.Version{Major: 2, Minor: 30, Name: "v2.30.0"}, arguments.CommandArgs{RequireSigned: true, GPGKeyring: "keys.gpg"}, ""},
+		{git.Version{Major: 2, Minor: 11, Name: "v2.11.0"}, arguments.CommandArgs{IsolateGit: true},
+			"Git v2.11.0 doesn't support protocol-allow, it needs v2.12.0 or newer"},
+		{git.Version{Major: 2, Minor: 11, Name: "v2.11.0"}, arguments.CommandArgs{}, ""},
+		{git.Version{Major: 2, Minor: 3, Name: "v2.3.0"}, arguments.CommandArgs{Command: "teardown"},
+			"Git v2.3.0 doesn't support atomic-push, it needs v2.4.0 or newer"},
+	}
+	for _, test := range tests {
+		err := checkGitVersion(test.version, test.args)
+		if (test.want == "" && err != nil) || (test.want != "" && (err == nil || err.Error() != test.want)) {
+			t.Errorf("Received the wrong error for %v: %v, want %q", test.version, err, test.want)
+		}
+	}
+}
+
+func TestMainGitTooOld(t *testing.T) {
+	defer restoreMocks()
+	fmtPrint = mockPrint([]string{
+		"Git v1.2.3 is older than --min-git-version v2.20.0\n",
+	}, t)
+	getGitVersion = mockVersion
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		onBranch(args)
+		args.MinGitVersion = "2.20.0"
+	})
+	mainUntilExit(exitGitMissing, t)
+}
+
+func TestUseCapabilities(t *testing.T) {
+	defer restoreMocks()
+	tests := map[string][]string{
+		"2.17.1":       {},
+		"2.18.0":       {"protocol.version=2"},
+		"custom.build": {},
+	}
+	for version, want := range tests {
+		config := []string{}
+		gitSetConfig = func(key, value string) { config = append(config, key+"="+value) }
+		parsed, err := git.ParseVersion(version)
+		if err != nil {
+			parsed = git.Version{Name: "v" + version}
+		}
+		useCapabilities(parsed)
+		if !reflect.DeepEqual(config, want) {
+			t.Errorf("Received the wrong config for %v: %v, want %v", version, config, want)
+		}
+	}
+}
@@ -7,7 +7,8 @@ pkg_license=('BSD 3-clause')
 pkg_deps=(core/git core/busybox-static)
 pkg_bin_dirs=(bin)
 
-scaffolding_go_build_deps=(github.com/fatih/color)
+# Dependencies are pinned by go.mod and go.sum, go-git/v5 can only be fetched as a module
+export GO111MODULE=on
 
 do_build() {
     export VERSION="${pkg_version}"
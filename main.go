@@ -3,10 +3,14 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/stjohnjohnson/bookend-scm-github/arguments"
 	"github.com/stjohnjohnson/bookend-scm-github/git"
+	"github.com/stjohnjohnson/bookend-scm-github/github"
+	"github.com/stjohnjohnson/bookend-scm-github/report"
 )
 
 // VERSION gets set by the build script via the LDFLAGS
@@ -14,72 +18,336 @@ var VERSION string
 
 var fmtPrint = fmt.Print
 var osExit = os.Exit
+var osChdir = os.Chdir
+var osGetEnv = os.Getenv
 var getArguments = arguments.GetArguments
 var getGitVersion = git.GetGitVersion
 var getGitSha = git.GetGitSha
+var getGitRevision = git.GetGitRevision
+var getChangedFiles = git.GetChangedFiles
+var getMergeBase = git.GetMergeBase
+var isAncestor = git.IsAncestor
+var hasChanges = git.HasChanges
+var getSignatures = git.GetSignatures
+var getCommitMessages = git.GetCommitMessages
+var gitSetenv = git.Setenv
+var gitSetConfig = git.SetConfig
 var executeStream = git.ExecuteStream
+var bytesReceived = git.BytesReceived
+var isolateGit = git.Isolate
+var useNativeGit = git.UseNative
+var setProgress = git.SetProgress
 var blackColor = color.New(color.FgHiBlack).SprintFunc()
 var redColor = color.New(color.FgHiRed).SprintFunc()
 var greenColor = color.New(color.FgHiGreen).SprintFunc()
 
+// result collects what happened during this run, resultFile is where to save
+// it and metricsFile where to save it for Prometheus
+var result = &report.Report{}
+var resultFile string
+var metricsFile string
+
+// cleanupGit removes the isolated Git environment (if any) before we exit
+var cleanupGit = func() {}
+
+// saveResult records the outcome of the run in the result and metrics files (if requested)
+func saveResult(success bool) {
+	result.EndStep()
+	result.Success = success
+	if resultFile != "" {
+		if err := result.WriteFile(resultFile); err != nil {
+			fmtPrint(redColor(fmt.Sprintf("Unable to write result file: %v\n", err)))
+		}
+	}
+	if metricsFile != "" {
+		if err := result.WriteMetrics(metricsFile); err != nil {
+			fmtPrint(redColor(fmt.Sprintf("Unable to write metrics file: %v\n", err)))
+		}
+	}
+	exportTrace()
+}
+
+func fail(code int, message string) {
+	if sig := stopSignal(); sig != nil {
+		code, message = exitInterrupted, fmt.Sprintf("Stopped by signal: %v\n", sig)
+		if partialDir != "" {
+			fmtPrint(redColor(fmt.Sprintf("Removing partial checkout from %s\n", partialDir)))
+			removeContents(partialDir)
+		}
+	}
+	fmtPrint(redColor(message))
+	reportStatus(github.StateFailure, failureDescription(code, message))
+	result.Fail(strings.TrimSpace(message))
+	result.ExitCode = code
+	saveResult(false)
+	cleanupGit()
+	osExit(code)
+}
+
+// skip ends a run that worked but left nothing for the build to do, the exit
+// code tells the pipeline why it can stop here
+func skip(code int, reason string) {
+	if stopped() {
+		return
+	}
+	fmtPrint(greenColor(fmt.Sprintf("\n✓ %s\n", reason)))
+	reportStatus(github.StateSuccess, reason)
+	if stopped() {
+		return
+	}
+	partialDir = ""
+	result.SkipReason = reason
+	result.ExitCode = code
+	saveResult(true)
+	cleanupGit()
+	osExit(code)
+}
+
 func executeStreamFail(args ...string) {
+	result.AddCommand(maskCommand(args))
 	err := executeStream(args...)
+	result.AddBytes(bytesReceived())
 	if err != nil {
-		fmtPrint(redColor(fmt.Sprintf("%v\n", err)))
-		osExit(1)
+		fail(exitCodeFor(err), fmt.Sprintf("%v\n", err))
 		return
 	}
 }
 
+func resolveFail(ref string) string {
+	sha, err := getGitRevision(ref)
+	if err != nil {
+		fail(exitSHANotFound, fmt.Sprintf("%v\n", err))
+	}
+	return sha
+}
+
+// checkSignatures stops the checkout if --require-signed is used and any of
+// the commits aren't signed by an allowed key
+func checkSignatures(args arguments.CommandArgs, revisions ...string) {
+	if !args.RequireSigned {
+		return
+	}
+	result.StartStep("signatures")
+	fmtPrint(greenColor("\n☛ Checking commit signatures\n"))
+	if err := verifySignatures(args, revisions...); err != nil {
+		fail(exitVerificationFailed, fmt.Sprintf("%v\n", err))
+	}
+}
+
 func main() {
 	args, err := getArguments(os.Args)
 	if args.Version {
 		fmtPrint(VERSION)
-		osExit(0)
+		osExit(exitOK)
 		return
 	}
+
+	result = &report.Report{
+		Host:        args.Host,
+		Repo:        args.Repo,
+		Branch:      args.Branch,
+		PullRequest: args.PullRequest,
+	}
+	resultFile = args.ResultFile
+	// The metrics are only for checkouts, teardown mustn't replace them
+	metricsFile = ""
+	if args.Command == "checkout" {
+		metricsFile = args.MetricsFile
+	}
+	secrets = nil
+	if args.HTTPSToken != "" {
+		secrets = append(secrets, args.HTTPSToken)
+	}
+
 	if err != nil {
-		fmtPrint(redColor(fmt.Sprintf("CLI flags invalid: %v\n", err)))
-		osExit(1)
+		fail(exitInvalidArguments, fmt.Sprintf("CLI flags invalid: %v\n", err))
 		return
 	}
 
+	// Plans print the isolation instead (see startPlan)
+	if args.IsolateGit && !args.Plan {
+		cleanup, err := isolateGit()
+		if err != nil {
+			fail(exitInternal, fmt.Sprintf("Unable to isolate Git: %v\n", err))
+			return
+		}
+		cleanupGit = cleanup
+		defer cleanup()
+	}
+	if args.Backend == "native" {
+		useNativeGit()
+	}
+	setProgress(args.Progress)
+	handleSignals()
+
+	switch args.Command {
+	case "teardown":
+		teardown(args)
+	case "verify":
+		verify(args)
+	case "doctor":
+		doctor(args)
+	default:
+		checkout(args)
+	}
+}
+
+// checkout clones the repository and gets it to the requested commit
+func checkout(args arguments.CommandArgs) {
+	startTrace(args)
+	if args.Plan {
+		// Plans only print what would happen, so nothing gets written
+		resultFile, metricsFile, args.MetaFile, args.EnvFile = "", "", "", ""
+		args.ChangedFiles, args.ChangedFilesJSON = "", ""
+		startPlan(args)
+	}
+
+	setupStatus(args)
+	reportStatus(github.StatePending, fmt.Sprintf("Checking out %s", args.Branch))
+
 	clientVersion, err := getGitVersion()
 	if err != nil {
-		fmtPrint(redColor(fmt.Sprintf("Unable to get Git version: %v\n", err)))
-		osExit(1)
+		fail(exitGitMissing, fmt.Sprintf("Unable to get Git version: %v\n", err))
+		return
+	}
+	if err = checkGitVersion(clientVersion, args); err != nil {
+		fail(exitGitMissing, fmt.Sprintf("%v\n", err))
 		return
 	}
+	useCapabilities(clientVersion)
+	result.GitVersion = clientVersion.String()
 
 	fmtPrint(fmt.Sprintf("%s\tv%s\n", blackColor("Bookend:"), VERSION))
 	fmtPrint(fmt.Sprintf("%s\t%s\n", blackColor("Git Client:"), clientVersion))
 
+	// Only this checkout's own directory is ever emptied
+	partialDir = ""
+	if dir, err := filepath.Abs(args.TargetDir); err == nil && !args.KeepOnFailure && !args.Plan {
+		partialDir = dir
+	}
+
+	result.StartStep("clone")
 	fmtPrint(greenColor(fmt.Sprintf("\n☛ Cloning %s, on branch %s\n", args.ScmURL, args.Branch)))
 	executeStreamFail("clone", "--quiet", "--progress", "--branch", args.Branch, args.CloneURL, args.TargetDir)
-	os.Chdir(args.TargetDir)
+	osChdir(args.TargetDir)
+	result.BaseSHA = resolveFail("HEAD")
 
+	result.StartStep("config")
 	fmtPrint(greenColor("\n☛ Saving local git config\n"))
 	executeStreamFail("config", "user.name", args.GitName)
 	executeStreamFail("config", "user.email", args.GitEmail)
 
 	if args.PullRequest != 0 {
+		result.StartStep("fetch")
 		fmtPrint(greenColor(fmt.Sprintf("\n☛ Fetching PR %d\n", args.PullRequest)))
-		executeStreamFail("fetch", "origin", fmt.Sprintf("pull/%d/head:pr", args.PullRequest))
+		executeStreamFail("fetch", "--progress", "origin", fmt.Sprintf("pull/%d/head:pr", args.PullRequest))
+		result.PRHeadSHA = resolveFail("pr")
+		checkSignatures(args, result.BaseSHA+".."+args.SHA)
+		if skipCI(args, result.BaseSHA+".."+args.SHA) {
+			return
+		}
 
+		result.StartStep("merge")
+		result.MergeStrategy = "merge"
 		fmtPrint(greenColor(fmt.Sprintf("\n☛ Merging with %s\n", args.Branch)))
 		executeStreamFail("merge", "--no-edit", args.SHA)
 
 		gitSha, err := getGitSha()
 		if err != nil {
-			fmtPrint(redColor(fmt.Sprintf("Unable to get current Git revision: %v\n", err)))
-			osExit(1)
+			fail(exitInternal, fmt.Sprintf("Unable to get current Git revision: %v\n", err))
 			return
 		}
+		result.HeadSHA = gitSha
 		fmtPrint(greenColor(fmt.Sprintf("\n☛ Checked out %s", gitSha)))
+
+		listChanges(result.BaseSHA, result.PRHeadSHA)
 	} else {
+		if args.VerifyBranch {
+			result.StartStep("verify-branch")
+			fmtPrint(greenColor(fmt.Sprintf("\n☛ Checking %s is on %s\n", args.SHA, args.Branch)))
+			onBranch, err := isAncestor(args.SHA, result.BaseSHA)
+			if err != nil {
+				fail(exitSHANotFound, fmt.Sprintf("%v\n", err))
+				return
+			}
+			if !onBranch {
+				fail(exitVerificationFailed, fmt.Sprintf("%s is not on branch %s (tip is %s), use --verify-branch=false to allow it\n", args.SHA, args.Branch, result.BaseSHA))
+				return
+			}
+		}
+		checkSignatures(args, "--max-count=1", args.SHA)
+		if skipCI(args, "--max-count=1", args.SHA) {
+			return
+		}
+
+		result.StartStep("reset")
+		result.MergeStrategy = "reset"
 		fmtPrint(greenColor(fmt.Sprintf("\n☛ Resetting to %s\n", args.SHA)))
 		executeStreamFail("reset", "--hard", args.SHA)
+		result.HeadSHA = resolveFail("HEAD")
+
+		if len(args.OnlyIfChanged) > 0 || args.ChangedFiles != "" || args.ChangedFilesJSON != "" {
+			if base := pushBase(args); base != "" {
+				listChanges(base, args.SHA)
+			}
+		}
 	}
 
+	result.SkipReason = onlyIfChanged(args)
+	if result.SkipReason == "" && !checkoutManifest(args) {
+		return
+	}
+	if stopped() {
+		return
+	}
+
+	// Everything after this is bookkeeping, so it isn't part of the timings
+	result.EndStep()
+
+	if args.MetaFile != "" {
+		if err = result.WriteMeta(args.MetaFile); err != nil {
+			fail(exitInternal, fmt.Sprintf("Unable to write Screwdriver meta: %v\n", err))
+			return
+		}
+	}
+
+	if args.EnvFile != "" {
+		if err = result.WriteEnvFile(args.EnvFile); err != nil {
+			fail(exitInternal, fmt.Sprintf("Unable to write env file: %v\n", err))
+			return
+		}
+	}
+
+	if args.ChangedFiles != "" {
+		if err = result.WriteChangedFiles(args.ChangedFiles); err != nil {
+			fail(exitInternal, fmt.Sprintf("Unable to write changed files: %v\n", err))
+			return
+		}
+	}
+
+	if args.ChangedFilesJSON != "" {
+		if err = result.WriteChangedFilesJSON(args.ChangedFilesJSON); err != nil {
+			fail(exitInternal, fmt.Sprintf("Unable to write changed files: %v\n", err))
+			return
+		}
+	}
+
+	if result.SkipReason != "" {
+		skip(exitNothingToBuild, result.SkipReason)
+		return
+	}
+	if stopped() {
+		return
+	}
+	reportStatus(github.StateSuccess, fmt.Sprintf("Checked out %s", result.HeadSHA))
+	// A signal while reporting the status still cancels the run
+	if stopped() {
+		return
+	}
+	partialDir = ""
 	fmtPrint(greenColor("\n✓ Done\n"))
+	if !args.Plan {
+		fmtPrint(blackColor("\n" + result.Summary()))
+	}
+	saveResult(true)
 }
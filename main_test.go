@@ -1,13 +1,19 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/fatih/color"
 	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+	"github.com/stjohnjohnson/bookend-scm-github/report"
 )
 
 func mockPrint(messages []string, t *testing.T) func(...interface{}) (int, error) {
@@ -45,6 +51,43 @@ func mockExec(commands []string, t *testing.T, fail bool) func(...string) error
 	}
 }
 
+func mockVersion() (git.Version, error) {
+	return git.Version{Major: 1, Minor: 2, Patch: 3, Name: "v1.2.3"}, nil
+}
+
+func mockRevision(ref string) (string, error) {
+	switch ref {
+	case "HEAD":
+		return "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5", nil
+	case "pr":
+		return "ace893fb2c9553a38a873fb03d0e21a406b351a1", nil
+	}
+	return "", fmt.Errorf("Unable to resolve %s", ref)
+}
+
+func mockChangedFiles(base, head string) ([]git.ChangedFile, error) {
+	return []git.ChangedFile{
+		{Status: "modified", Path: "README.md"},
+		{Status: "renamed", Path: "main.go", OldPath: "bookend.go"},
+	}, nil
+}
+
+func mockMergeBase(a, b string) (string, error) {
+	return "5e8c1d6a2b1d3f3e6b6a9f1f2a6e0f9e3c1b2a4d", nil
+}
+
+// mockSummary is the table printed after a run whose steps took no time at all
+func mockSummary(received int64, steps ...string) string {
+	r := report.Report{}
+	for i, name := range steps {
+		r.Steps = append(r.Steps, report.Step{Name: name})
+		if i == 0 {
+			r.Steps[0].Bytes = received
+		}
+	}
+	return "\n" + r.Summary()
+}
+
 func mockExit(want int, t *testing.T) func(int) {
 	return func(code int) {
 		if code != want {
@@ -53,8 +96,31 @@ func mockExit(want int, t *testing.T) func(int) {
 	}
 }
 
+// restoreMocks puts back the globals that --plan and the chdir mocks replace
+func restoreMocks() {
+	osChdir = os.Chdir
+	getMergeBase = mockMergeBase
+	cleanupGit = func() {}
+	partialDir = ""
+	stoppedBy.signal = nil
+	stoppedBy.waiting = nil
+	osStat = os.Stat
+	osRemoveAll = os.RemoveAll
+	excludePaths = func(string, ...string) error { return nil }
+	gitSetenv = func(string, string) {}
+	gitSetConfig = func(string, string) {}
+	color.NoColor = noColor
+}
+
+var noColor = color.NoColor
+
 func TestMain(m *testing.M) {
 	VERSION = "1.0.0"
+	notifySignals = func(chan<- os.Signal, ...os.Signal) {}
+	getMergeBase = mockMergeBase
+	excludePaths = func(string, ...string) error { return nil }
+	gitSetenv = func(string, string) {}
+	gitSetConfig = func(string, string) {}
 	os.Exit(m.Run())
 }
 
@@ -72,10 +138,22 @@ func TestMainNonPR(t *testing.T) {
 		"\n☛ Saving local git config\n",
 		"\n☛ Resetting to 302f5f5b48b9feee797a66c88811f1770bcb2dcf\n",
 		"\n✓ Done\n",
+		mockSummary(1394606, "clone", "config", "reset"),
 	}, t)
 	osExit = mockExit(0, t)
-	getGitVersion = func() (string, error) { return "v1.2.3", nil }
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
 	getGitSha = func() (string, error) { return "ace893fb2c9553a38a873fb03d0e21a406b351a1", nil }
+	cloned := false
+	bytesReceived = func() int64 {
+		if cloned {
+			return 0
+		}
+		cloned = true
+		return 1394606
+	}
+	defer func() { bytesReceived = git.BytesReceived }()
 
 	getArguments = func(_ []string) (arguments.CommandArgs, error) {
 		return arguments.CommandArgs{
@@ -96,12 +174,98 @@ func TestMainNonPR(t *testing.T) {
 	main()
 }
 
+// testArgs are the flags the tests run with, a checkout of master with
+// whatever change makes to them
+func testArgs(change func(args *arguments.CommandArgs)) func([]string) (arguments.CommandArgs, error) {
+	return func(_ []string) (arguments.CommandArgs, error) {
+		args := arguments.CommandArgs{
+			Command:     "checkout",
+			Host:        "github.com",
+			Repo:        "testOrg/testRepo",
+			Branch:      "master",
+			ScmURL:      "github.com/testOrg/testRepo",
+			SHA:         "ace893fb2c9553a38a873fb03d0e21a406b351a1",
+			TargetDir:   "/tmp/foo",
+			CloneMethod: "https",
+			CloneURL:    "https://github.com/testOrg/testRepo.git",
+			GitName:     "sd-buildbot",
+			GitEmail:    "dev-null@screwdriver.cd",
+		}
+		if change != nil {
+			change(&args)
+		}
+		return args, nil
+	}
+}
+
+// onBranch checks out 302f5f5 from master, making sure it's on the branch
+func onBranch(args *arguments.CommandArgs) {
+	args.SHA = "302f5f5b48b9feee797a66c88811f1770bcb2dcf"
+	args.VerifyBranch = true
+}
+
+func TestMainVerifyBranch(t *testing.T) {
+	defer restoreMocks()
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"reset --hard 302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+	}, t, false)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n",
+		"Git Client:\tv1.2.3\n",
+		"\n☛ Cloning github.com/testOrg/testRepo, on branch master\n",
+		"\n☛ Saving local git config\n",
+		"\n☛ Checking 302f5f5b48b9feee797a66c88811f1770bcb2dcf is on master\n",
+		"\n☛ Resetting to 302f5f5b48b9feee797a66c88811f1770bcb2dcf\n",
+		"\n✓ Done\n",
+		mockSummary(0, "clone", "config", "verify-branch", "reset"),
+	}, t)
+	osExit = mockExit(0, t)
+	osChdir = func(string) error { return nil }
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	isAncestor = func(ancestor, descendant string) (bool, error) {
+		if ancestor != "302f5f5b48b9feee797a66c88811f1770bcb2dcf" || descendant != "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5" {
+			t.Errorf("isAncestor(%q, %q) wasn't checked against the branch tip", ancestor, descendant)
+		}
+		return true, nil
+	}
+	getArguments = testArgs(onBranch)
+	main()
+}
+
+func TestMainNotOnBranch(t *testing.T) {
+	defer restoreMocks()
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+	}, t, false)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n",
+		"Git Client:\tv1.2.3\n",
+		"\n☛ Cloning github.com/testOrg/testRepo, on branch master\n",
+		"\n☛ Saving local git config\n",
+		"\n☛ Checking 302f5f5b48b9feee797a66c88811f1770bcb2dcf is on master\n",
+		"302f5f5b48b9feee797a66c88811f1770bcb2dcf is not on branch master (tip is 1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5), use --verify-branch=false to allow it\n",
+	}, t)
+	osExit = mockExit(exitVerificationFailed, t)
+	osChdir = func(string) error { return nil }
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	isAncestor = func(ancestor, descendant string) (bool, error) { return false, nil }
+	getArguments = testArgs(onBranch)
+	main()
+}
+
 func TestMainPR(t *testing.T) {
 	executeStream = mockExec([]string{
 		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
 		"config user.name sd-buildbot",
 		"config user.email dev-null@screwdriver.cd",
-		"fetch origin pull/15/head:pr",
+		"fetch --progress origin pull/15/head:pr",
 		"merge --no-edit ace893fb2c9553a38a873fb03d0e21a406b351a1",
 	}, t, false)
 	fmtPrint = mockPrint([]string{
@@ -113,9 +277,12 @@ func TestMainPR(t *testing.T) {
 		"\n☛ Merging with master\n",
 		"\n☛ Checked out 302f5f5b48b9feee797a66c88811f1770bcb2dcf",
 		"\n✓ Done\n",
+		mockSummary(0, "clone", "config", "fetch", "merge"),
 	}, t)
 	osExit = mockExit(0, t)
-	getGitVersion = func() (string, error) { return "v1.2.3", nil }
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
 	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
 
 	getArguments = func(_ []string) (arguments.CommandArgs, error) {
@@ -155,9 +322,12 @@ func TestMainFailCommand(t *testing.T) {
 		"\n☛ Resetting to 302f5f5b48b9feee797a66c88811f1770bcb2dcf\n",
 		"Failed command\n",
 		"\n✓ Done\n",
+		mockSummary(0, "clone", "config", "reset"),
 	}, t)
 	osExit = mockExit(1, t)
-	getGitVersion = func() (string, error) { return "v1.2.3", nil }
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
 	getGitSha = func() (string, error) { return "ace893fb2c9553a38a873fb03d0e21a406b351a1", nil }
 
 	getArguments = func(_ []string) (arguments.CommandArgs, error) {
@@ -184,8 +354,10 @@ func TestMainNoVersion(t *testing.T) {
 	fmtPrint = mockPrint([]string{
 		"Unable to get Git version: Bad Version\n",
 	}, t)
-	osExit = mockExit(1, t)
-	getGitVersion = func() (string, error) { return "", errors.New("Bad Version") }
+	osExit = mockExit(3, t)
+	getGitVersion = func() (git.Version, error) { return git.Version{}, errors.New("Bad Version") }
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
 	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
 
 	getArguments = func(_ []string) (arguments.CommandArgs, error) {
@@ -207,12 +379,50 @@ func TestMainNoVersion(t *testing.T) {
 	main()
 }
 
+func TestMainIsolateGit(t *testing.T) {
+	defer restoreMocks()
+	executeStream = mockExec([]string{}, t, false)
+	fmtPrint = mockPrint([]string{
+		"Unable to get Git version: Bad Version\n",
+	}, t)
+	osExit = mockExit(3, t)
+	getGitVersion = func() (git.Version, error) { return git.Version{}, errors.New("Bad Version") }
+
+	cleaned := 0
+	isolateGit = func() (func(), error) { return func() { cleaned++ }, nil }
+	defer func() { isolateGit = git.Isolate }()
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		onBranch(args)
+		args.IsolateGit = true
+	})
+	main()
+
+	if cleaned == 0 {
+		t.Errorf("Expected the isolated Git environment to be removed")
+	}
+}
+
+func TestMainIsolateGitFails(t *testing.T) {
+	defer restoreMocks()
+	fmtPrint = mockPrint([]string{
+		"Unable to isolate Git: no space left on device\n",
+	}, t)
+	osExit = mockExit(exitInternal, t)
+	isolateGit = func() (func(), error) { return nil, errors.New("no space left on device") }
+	defer func() { isolateGit = git.Isolate }()
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		onBranch(args)
+		args.IsolateGit = true
+	})
+	main()
+}
+
 func TestMainBadArgs(t *testing.T) {
 	executeStream = mockExec([]string{}, t, false)
 	fmtPrint = mockPrint([]string{
 		"CLI flags invalid: --foo is required\n",
 	}, t)
-	osExit = mockExit(1, t)
+	osExit = mockExit(2, t)
 	getArguments = func(_ []string) (arguments.CommandArgs, error) {
 		return arguments.CommandArgs{}, errors.New("--foo is required")
 	}
@@ -224,7 +434,7 @@ func TestMainNoSha(t *testing.T) {
 		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
 		"config user.name sd-buildbot",
 		"config user.email dev-null@screwdriver.cd",
-		"fetch origin pull/15/head:pr",
+		"fetch --progress origin pull/15/head:pr",
 		"merge --no-edit ace893fb2c9553a38a873fb03d0e21a406b351a1",
 	}, t, false)
 	fmtPrint = mockPrint([]string{
@@ -237,7 +447,9 @@ func TestMainNoSha(t *testing.T) {
 		"Unable to get current Git revision: Bad Revision\n",
 	}, t)
 	osExit = mockExit(1, t)
-	getGitVersion = func() (string, error) { return "v1.2.3", nil }
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
 	getGitSha = func() (string, error) { return "", errors.New("Bad Revision") }
 
 	getArguments = func(_ []string) (arguments.CommandArgs, error) {
@@ -273,3 +485,370 @@ func TestMainVersion(t *testing.T) {
 	}
 	main()
 }
+
+func TestMainResultFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	resultPath := filepath.Join(dir, "result.json")
+
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"fetch --progress origin pull/15/head:pr",
+		"merge --no-edit ace893fb2c9553a38a873fb03d0e21a406b351a1",
+	}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(0, t)
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
+	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
+
+	getArguments = func(_ []string) (arguments.CommandArgs, error) {
+		return arguments.CommandArgs{
+			Host:        "github.com",
+			Repo:        "testOrg/testRepo",
+			Branch:      "master",
+			ScmURL:      "github.com/testOrg/testRepo",
+			SHA:         "ace893fb2c9553a38a873fb03d0e21a406b351a1",
+			PullRequest: 15,
+			TargetDir:   "/tmp/foo",
+			CloneMethod: "https",
+			CloneURL:    "https://github.com/testOrg/testRepo.git",
+			GitName:     "sd-buildbot",
+			GitEmail:    "dev-null@screwdriver.cd",
+			ResultFile:  resultPath,
+		}, nil
+	}
+	main()
+
+	got := readResult(resultPath, t)
+	if !got.Success || got.Error != "" {
+		t.Errorf("Expected a successful result, got %v / %q", got.Success, got.Error)
+	}
+	if got.BaseSHA != "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5" {
+		t.Errorf("Received the wrong base sha: %v", got.BaseSHA)
+	}
+	if got.PRHeadSHA != "ace893fb2c9553a38a873fb03d0e21a406b351a1" {
+		t.Errorf("Received the wrong PR head sha: %v", got.PRHeadSHA)
+	}
+	if got.HeadSHA != "302f5f5b48b9feee797a66c88811f1770bcb2dcf" {
+		t.Errorf("Received the wrong head sha: %v", got.HeadSHA)
+	}
+	if len(got.ChangedFiles) != 2 {
+		t.Errorf("Received the wrong changed files: %v", got.ChangedFiles)
+	}
+	if got.PullRequest != 15 || got.MergeStrategy != "merge" || got.GitVersion != "v1.2.3" {
+		t.Errorf("Received the wrong metadata: %+v", got)
+	}
+
+	steps := []string{}
+	for _, step := range got.Steps {
+		steps = append(steps, step.Name)
+	}
+	wantSteps := "clone config fetch merge"
+	if strings.Join(steps, " ") != wantSteps {
+		t.Errorf("Received the wrong steps: %v, want %v", steps, wantSteps)
+	}
+}
+
+func TestMainChangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	textPath := filepath.Join(dir, "changed.txt")
+	jsonPath := filepath.Join(dir, "changed.json")
+
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"fetch --progress origin pull/15/head:pr",
+		"merge --no-edit ace893fb2c9553a38a873fb03d0e21a406b351a1",
+	}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(0, t)
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = func(base, head string) ([]git.ChangedFile, error) {
+		if base != "5e8c1d6a2b1d3f3e6b6a9f1f2a6e0f9e3c1b2a4d" || head != "ace893fb2c9553a38a873fb03d0e21a406b351a1" {
+			t.Errorf("Compared the wrong commits: %v...%v", base, head)
+		}
+		return mockChangedFiles(base, head)
+	}
+	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
+
+	getArguments = func(_ []string) (arguments.CommandArgs, error) {
+		return arguments.CommandArgs{
+			Host:             "github.com",
+			Repo:             "testOrg/testRepo",
+			Branch:           "master",
+			ScmURL:           "github.com/testOrg/testRepo",
+			SHA:              "ace893fb2c9553a38a873fb03d0e21a406b351a1",
+			PullRequest:      15,
+			TargetDir:        "/tmp/foo",
+			CloneMethod:      "https",
+			CloneURL:         "https://github.com/testOrg/testRepo.git",
+			GitName:          "sd-buildbot",
+			GitEmail:         "dev-null@screwdriver.cd",
+			ChangedFiles:     textPath,
+			ChangedFilesJSON: jsonPath,
+		}, nil
+	}
+	main()
+
+	text, err := ioutil.ReadFile(textPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "modified\tREADME.md\nrenamed\tmain.go\tbookend.go\n"
+	if string(text) != want {
+		t.Errorf("Received the wrong changed files:\n%q\nwant:\n%q", string(text), want)
+	}
+
+	contents, err := ioutil.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes report.Changes
+	if err = json.Unmarshal(contents, &changes); err != nil {
+		t.Fatal(err)
+	}
+	if changes.MergeBaseSHA != "5e8c1d6a2b1d3f3e6b6a9f1f2a6e0f9e3c1b2a4d" ||
+		changes.HeadSHA != "ace893fb2c9553a38a873fb03d0e21a406b351a1" ||
+		len(changes.Files) != 2 || changes.Files[1].OldPath != "bookend.go" {
+		t.Errorf("Received the wrong changed files: %+v", changes)
+	}
+}
+
+func TestMainResultFileFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	resultPath := filepath.Join(dir, "result.json")
+
+	executeStream = mockExec([]string{}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(3, t)
+	getGitVersion = func() (git.Version, error) { return git.Version{}, errors.New("Bad Version") }
+
+	getArguments = func(_ []string) (arguments.CommandArgs, error) {
+		return arguments.CommandArgs{
+			Host:        "github.com",
+			Repo:        "testOrg/testRepo",
+			Branch:      "master",
+			SHA:         "302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+			PullRequest: 0,
+			TargetDir:   "/tmp/foo",
+			ResultFile:  resultPath,
+		}, nil
+	}
+	main()
+
+	got := readResult(resultPath, t)
+	if got.Success {
+		t.Errorf("Expected a failed result")
+	}
+	wantErr := "Unable to get Git version: Bad Version"
+	if got.Error != wantErr || got.ExitCode != exitGitMissing {
+		t.Errorf("Received the wrong error: %q (%v), want %q (%v)", got.Error, got.ExitCode, wantErr, exitGitMissing)
+	}
+	if got.Repo != "testOrg/testRepo" || got.Branch != "master" {
+		t.Errorf("Received the wrong metadata: %+v", got)
+	}
+}
+
+func TestMainMetaFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	metaPath := filepath.Join(dir, "meta.json")
+	ioutil.WriteFile(metaPath, []byte(`{"build":{"id":"1"}}`), 0644)
+
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"fetch --progress origin pull/15/head:pr",
+		"merge --no-edit ace893fb2c9553a38a873fb03d0e21a406b351a1",
+	}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(0, t)
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
+	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
+
+	getArguments = func(_ []string) (arguments.CommandArgs, error) {
+		return arguments.CommandArgs{
+			Host:        "github.com",
+			Repo:        "testOrg/testRepo",
+			Branch:      "master",
+			ScmURL:      "github.com/testOrg/testRepo",
+			SHA:         "ace893fb2c9553a38a873fb03d0e21a406b351a1",
+			PullRequest: 15,
+			TargetDir:   "/tmp/foo",
+			CloneURL:    "https://github.com/testOrg/testRepo.git",
+			GitName:     "sd-buildbot",
+			GitEmail:    "dev-null@screwdriver.cd",
+			MetaFile:    metaPath,
+		}, nil
+	}
+	main()
+
+	bytes, _ := ioutil.ReadFile(metaPath)
+	var got struct {
+		Bookend report.Meta       `json:"bookend"`
+		Build   map[string]string `json:"build"`
+	}
+	if err = json.Unmarshal(bytes, &got); err != nil {
+		t.Fatalf("Unable to parse meta %s: %v", bytes, err)
+	}
+	if got.Bookend.SHA != "302f5f5b48b9feee797a66c88811f1770bcb2dcf" ||
+		got.Bookend.BaseSHA != "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5" ||
+		got.Bookend.PRHeadSHA != "ace893fb2c9553a38a873fb03d0e21a406b351a1" ||
+		got.Bookend.ChangedFiles != 2 {
+		t.Errorf("Received the wrong meta: %s", bytes)
+	}
+	if len(got.Bookend.Durations) != 4 {
+		t.Errorf("Expected a duration for clone, config, fetch and merge, got %v", got.Bookend.Durations)
+	}
+	if got.Build["id"] != "1" {
+		t.Errorf("Expected the existing meta to be kept, got %s", bytes)
+	}
+}
+
+func TestMainEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	envPath := filepath.Join(dir, "checkout.env")
+
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"reset --hard 302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+	}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(0, t)
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
+
+	getArguments = func(_ []string) (arguments.CommandArgs, error) {
+		return arguments.CommandArgs{
+			Host:      "github.com",
+			Repo:      "testOrg/testRepo",
+			Branch:    "master",
+			ScmURL:    "github.com/testOrg/testRepo",
+			SHA:       "302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+			TargetDir: "/tmp/foo",
+			CloneURL:  "https://github.com/testOrg/testRepo.git",
+			GitName:   "sd-buildbot",
+			GitEmail:  "dev-null@screwdriver.cd",
+			EnvFile:   envPath,
+		}, nil
+	}
+	main()
+
+	bytes, _ := ioutil.ReadFile(envPath)
+	want := `GIT_COMMIT='1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5'
+GIT_BASE_COMMIT='1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5'
+GIT_PR_HEAD=''
+GIT_BRANCH='master'
+GIT_REPO='testOrg/testRepo'
+`
+	if string(bytes) != want {
+		t.Errorf("Received the wrong env file:\n%s\nwant\n%s", bytes, want)
+	}
+}
+
+func TestMainMetricsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	metricsPath := filepath.Join(dir, "bookend.prom")
+
+	executeStream = mockExec([]string{}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(3, t)
+	getGitVersion = func() (git.Version, error) { return git.Version{}, errors.New("Bad Version") }
+
+	getArguments = func(_ []string) (arguments.CommandArgs, error) {
+		return arguments.CommandArgs{
+			Command:     "checkout",
+			Host:        "github.com",
+			Repo:        "testOrg/testRepo",
+			Branch:      "master",
+			SHA:         "302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+			TargetDir:   "/tmp/foo",
+			MetricsFile: metricsPath,
+		}, nil
+	}
+	main()
+
+	bytes, err := ioutil.ReadFile(metricsPath)
+	if err != nil {
+		t.Fatalf("Unable to read metrics file: %v", err)
+	}
+	for _, want := range []string{
+		`bookend_checkout_success{host="github.com",repo="testOrg/testRepo"} 0` + "\n",
+		`bookend_checkout_exit_code{host="github.com",repo="testOrg/testRepo"} 3` + "\n",
+	} {
+		if !strings.Contains(string(bytes), want) {
+			t.Errorf("Expected the metrics to contain %q, got:\n%s", want, bytes)
+		}
+	}
+}
+
+func TestMainMetricsFileBadArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	metricsPath := filepath.Join(dir, "bookend.prom")
+
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(exitInvalidArguments, t)
+	getArguments = func(_ []string) (arguments.CommandArgs, error) {
+		return arguments.CommandArgs{Command: "checkout", Host: "github.com", MetricsFile: metricsPath}, errors.New("--repo is required")
+	}
+	main()
+
+	bytes, err := ioutil.ReadFile(metricsPath)
+	if err != nil {
+		t.Fatalf("Expected metrics for invalid flags: %v", err)
+	}
+	want := `bookend_checkout_exit_code{host="github.com",repo=""} 2` + "\n"
+	if !strings.Contains(string(bytes), want) {
+		t.Errorf("Expected the metrics to contain %q, got:\n%s", want, bytes)
+	}
+}
+
+func readResult(path string, t *testing.T) report.Report {
+	var got report.Report
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read result file: %v", err)
+	}
+	if err = json.Unmarshal(bytes, &got); err != nil {
+		t.Fatalf("Unable to parse result file: %v", err)
+	}
+	return got
+}
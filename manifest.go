@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+	"github.com/stjohnjohnson/bookend-scm-github/report"
+)
+
+var executeIn = git.ExecuteIn
+var osStat = os.Stat
+var osRemoveAll = os.RemoveAll
+var excludePaths = git.Exclude
+
+// repoCheckout is how checking out one of the --manifest repositories went
+type repoCheckout struct {
+	commands []string
+	sha      string
+	err      error
+	// created is the top-most directory the clone made, removed again if
+	// any of the repositories fail
+	created string
+}
+
+// createdDir is the top-most directory of path that doesn't exist yet, so
+// removing it undoes a clone into path, or "" if path is already there
+func createdDir(path string) string {
+	dir := ""
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Clean(path)), "/") {
+		dir = filepath.Join(dir, part)
+		if _, err := osStat(dir); os.IsNotExist(err) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// checkoutRepo clones a --manifest repository into its path under the
+// checkout and gets it to its SHA (if it has one). They're always cloned
+// with GIT_PATH (even with --backend=native) and quietly, as they run at
+// the same time, using the same credentials, isolation and signal handling
+// as the main checkout.
+func checkoutRepo(repo arguments.ManifestRepo) repoCheckout {
+	var out repoCheckout
+	run := func(dir string, args ...string) string {
+		if out.err != nil {
+			return ""
+		}
+		if dir != "" {
+			out.commands = append(out.commands, maskCommand(append([]string{"-C", dir}, args...)))
+		} else {
+			out.commands = append(out.commands, maskCommand(args))
+		}
+		output, err := executeIn(dir, args...)
+		out.err = err
+		return strings.TrimSpace(output)
+	}
+
+	out.created = createdDir(repo.Path)
+
+	clone := []string{"clone", "--quiet"}
+	if repo.Ref != "" {
+		clone = append(clone, "--branch", repo.Ref)
+	}
+	run("", append(clone, repo.CloneURL, repo.Path)...)
+	if repo.SHA != "" {
+		run(repo.Path, "reset", "--quiet", "--hard", repo.SHA)
+	}
+	out.sha = run(repo.Path, "rev-parse", "HEAD")
+	return out
+}
+
+// checkoutManifest checks out the --manifest repositories alongside the main
+// one, all at once. If any of them fail none of them are kept, and it returns false.
+func checkoutManifest(args arguments.CommandArgs) bool {
+	if len(args.ManifestRepos) == 0 {
+		return true
+	}
+	result.StartStep("manifest")
+	fmtPrint(greenColor(fmt.Sprintf("\n☛ Checking out %d repositories from %s\n", len(args.ManifestRepos), args.Manifest)))
+
+	checkouts := make([]repoCheckout, len(args.ManifestRepos))
+	var wg sync.WaitGroup
+	for i, repo := range args.ManifestRepos {
+		if args.Plan {
+			// Plans are printed in order
+			checkouts[i] = checkoutRepo(repo)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, repo arguments.ManifestRepo) {
+			defer wg.Done()
+			checkouts[i] = checkoutRepo(repo)
+		}(i, repo)
+	}
+	wg.Wait()
+
+	failed := -1
+	for i, checkout := range checkouts {
+		for _, command := range checkout.commands {
+			result.AddCommand(command)
+		}
+		if checkout.err != nil && failed == -1 {
+			failed = i
+		}
+	}
+	if failed != -1 {
+		for _, checkout := range checkouts {
+			if checkout.created != "" {
+				osRemoveAll(checkout.created)
+			}
+		}
+		err := checkouts[failed].err
+		fail(exitCodeFor(err), fmt.Sprintf("Unable to check out %s: %v\n", args.ManifestRepos[failed].Repo, err))
+		return false
+	}
+
+	// Keep them out of the main repository's git status, and teardown's git add
+	paths := make([]string, len(args.ManifestRepos))
+	for i, repo := range args.ManifestRepos {
+		paths[i] = repo.Path
+	}
+	if err := excludePaths("", paths...); err != nil {
+		fail(exitInternal, fmt.Sprintf("Unable to exclude the repositories from Git: %v\n", err))
+		return false
+	}
+
+	for i, repo := range args.ManifestRepos {
+		result.Repos = append(result.Repos, report.Repo{
+			Host: repo.Host,
+			Repo: repo.Repo,
+			Ref:  repo.Ref,
+			Path: repo.Path,
+			SHA:  checkouts[i].sha,
+		})
+		fmtPrint(fmt.Sprintf("%s\t%s at %s\n", blackColor(repo.Path+":"), repo.Repo, checkouts[i].sha))
+	}
+	return true
+}
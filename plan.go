@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+	"github.com/stjohnjohnson/bookend-scm-github/report"
+)
+
+// Placeholders for values we only know once Git has run are wrapped in this
+const placeholder = "\x00"
+
+var safeWord = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,-]+$`)
+var placeholderPattern = regexp.MustCompile(placeholder + `(\w+)` + placeholder)
+
+// planner stands in for Git when using --plan, printing each command as a
+// line of shell instead of running it
+type planner struct {
+	shell    bool
+	secrets  map[string]string
+	print    func(...interface{}) (int, error)
+	captured int
+	// isolated are the options Git gets with --isolate-git
+	isolated []string
+}
+
+// startPlan swaps out everything that would touch Git or the disk
+func startPlan(args arguments.CommandArgs) {
+	p := &planner{
+		shell:   args.PlanFormat == "shell",
+		secrets: map[string]string{},
+		print:   fmtPrint,
+	}
+	if args.HTTPSToken != "" {
+		p.secrets[args.HTTPSToken] = "BOOKEND_HTTPS_TOKEN"
+	}
+
+	if args.IsolateGit {
+		p.isolated = git.IsolatedArgs()
+	}
+
+	color.NoColor = true
+	if p.shell {
+		p.print("#!/bin/sh\n")
+		what := fmt.Sprintf("Checkout of %s at %s", args.ScmURL, args.SHA)
+		if args.Command == "teardown" {
+			what = fmt.Sprintf("Teardown of %s", args.ScmURL)
+		}
+		p.print(fmt.Sprintf("# %s, planned by bookend-scm-github v%s\n", what, VERSION))
+		p.print("set -eu\n")
+		if args.IsolateGit {
+			p.print(fmt.Sprintf("git() { command \"${GIT_PATH:-git}\" %s \"$@\" < /dev/null; }\n", strings.Join(p.isolated, " ")))
+		} else {
+			p.print("git() { command \"${GIT_PATH:-git}\" \"$@\"; }\n")
+		}
+	}
+	if args.IsolateGit {
+		p.isolate()
+	}
+
+	fmtPrint = p.comment
+	executeStream = p.execute
+	osChdir = p.chdir
+	getGitVersion = p.version
+	getGitSha = func() (string, error) { return p.capture("rev-parse", "HEAD"), nil }
+	getGitRevision = func(ref string) (string, error) {
+		return p.capture("rev-parse", "--verify", ref+"^{commit}"), nil
+	}
+	executeIn = func(dir string, args ...string) (string, error) {
+		if dir != "" {
+			args = append([]string{"-C", dir}, args...)
+		}
+		if args[len(args)-1] == "HEAD" && args[len(args)-2] == "rev-parse" {
+			return p.capture(args...), nil
+		}
+		return "", p.execute(args...)
+	}
+	excludePaths = func(dir string, paths ...string) error {
+		lines := make([]string, len(paths))
+		for i, path := range paths {
+			lines[i] = p.quote(git.ExcludePattern(path))
+		}
+		p.print(fmt.Sprintf("printf '%%s\\n' %s >> %s\n", strings.Join(lines, " "), p.quote(filepath.Join(dir, ".git", "info", "exclude"))))
+		return nil
+	}
+	gitSetenv = func(key, value string) {
+		p.print(fmt.Sprintf("export %s=%s\n", key, p.quote(value)))
+	}
+	getSignatures = func(allowedSigners string, revisions ...string) ([]git.Signature, error) {
+		return nil, p.execute(git.SignatureArgs(allowedSigners, revisions...)...)
+	}
+	isAncestor = func(ancestor, descendant string) (bool, error) {
+		return true, p.execute("merge-base", "--is-ancestor", ancestor, descendant)
+	}
+	hasChanges = func() (bool, error) {
+		return true, p.execute("status", "--porcelain")
+	}
+	getMergeBase = func(a, b string) (string, error) {
+		return p.capture("merge-base", a, b), nil
+	}
+	getChangedFiles = func(base, head string) ([]git.ChangedFile, error) {
+		return nil, p.execute(git.ChangedFilesArgs(base, head)...)
+	}
+}
+
+// quote renders a single argument for a POSIX shell, masking secrets
+func (p *planner) quote(arg string) string {
+	for secret, name := range p.secrets {
+		if p.shell {
+			arg = strings.Replace(arg, secret, placeholder+name+placeholder, -1)
+		} else {
+			arg = strings.Replace(arg, secret, "****", -1)
+		}
+	}
+
+	quoted := ""
+	for i, part := range strings.Split(arg, placeholder) {
+		switch {
+		case i%2 == 1:
+			quoted += fmt.Sprintf(`"${%s}"`, part)
+		case safeWord.MatchString(part):
+			quoted += part
+		case part != "":
+			quoted += report.ShellQuote(part)
+		}
+	}
+	return quoted
+}
+
+func (p *planner) line(words ...string) {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = p.quote(word)
+	}
+	p.print(strings.Join(quoted, " ") + "\n")
+}
+
+// comment turns the progress messages into shell comments
+func (p *planner) comment(input ...interface{}) (int, error) {
+	message := placeholderPattern.ReplaceAllString(fmt.Sprint(input...), "$${$1}")
+	for _, line := range strings.Split(message, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			p.print("# " + line + "\n")
+		}
+	}
+	return 0, nil
+}
+
+// isolate prints what git.Isolate does: GPG and SSH keep the real HOME, and
+// Git gets an empty one that's removed again when the script exits
+func (p *planner) isolate() {
+	p.print("BOOKEND_HOME=$(mktemp -d)\n")
+	p.print("trap 'rm -rf \"${BOOKEND_HOME}\"' EXIT\n")
+	p.print("[ -n \"${GNUPGHOME:-}\" ] || [ -z \"${HOME:-}\" ] || export GNUPGHOME=\"${HOME}/.gnupg\"\n")
+	p.print("[ -n \"${GIT_SSH_COMMAND:-}${GIT_SSH:-}\" ] || export GIT_SSH_COMMAND='ssh -o BatchMode=yes'\n")
+	for _, env := range git.IsolatedEnv(placeholder + "BOOKEND_HOME" + placeholder) {
+		p.print(fmt.Sprintf("export %s=%s\n", env[0], p.quote(env[1])))
+	}
+}
+
+// git is the command line for running Git with args, in shell scripts the
+// git function already adds the --isolate-git options
+func (p *planner) git(args ...string) []string {
+	if p.shell {
+		return append([]string{"git"}, args...)
+	}
+	return append(append([]string{"git"}, p.isolated...), args...)
+}
+
+func (p *planner) execute(args ...string) error {
+	p.line(p.git(args...)...)
+	return nil
+}
+
+func (p *planner) chdir(dir string) error {
+	p.line("cd", dir)
+	return nil
+}
+
+func (p *planner) version() (git.Version, error) {
+	p.line(p.git("--version")...)
+	return git.Version{Name: "(not checked when planning)"}, nil
+}
+
+// capture prints a command whose output we need later, returning a
+// placeholder for the shell variable it is stored in
+func (p *planner) capture(args ...string) string {
+	p.captured++
+	name := fmt.Sprintf("SHA_%d", p.captured)
+
+	command := p.git(args...)
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = p.quote(arg)
+	}
+	p.print(fmt.Sprintf("%s=$(%s)\n", name, strings.Join(quoted, " ")))
+
+	return placeholder + name + placeholder
+}
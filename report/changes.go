@@ -0,0 +1,89 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ChangedFile is a file changed by the pull request (or push)
+type ChangedFile struct {
+	// Status is added, modified, renamed, copied or deleted
+	Status string `json:"status"`
+	Path   string `json:"path"`
+	// OldPath is where a renamed or copied file came from
+	OldPath string `json:"old_path,omitempty"`
+}
+
+// Changes is the JSON form of the changed files
+type Changes struct {
+	MergeBaseSHA string        `json:"merge_base_sha"`
+	HeadSHA      string        `json:"head_sha"`
+	Files        []ChangedFile `json:"files"`
+}
+
+// pathEscapes are the characters Git writes as C escapes when quoting a path
+var pathEscapes = map[byte]string{
+	'\a': `\a`, '\b': `\b`, '\t': `\t`, '\n': `\n`, '\v': `\v`, '\f': `\f`, '\r': `\r`,
+	'"': `\"`, '\\': `\\`,
+}
+
+// quotePath quotes a path the way Git does without -z: in double quotes with
+// C-style escapes if it has control characters, quotes, backslashes or
+// anything that isn't ASCII, and as it is otherwise
+func quotePath(path string) string {
+	var out strings.Builder
+	quote := false
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch escape, ok := pathEscapes[c]; {
+		case ok:
+			out.WriteString(escape)
+			quote = true
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&out, `\%03o`, c)
+			quote = true
+		default:
+			out.WriteByte(c)
+		}
+	}
+	if !quote {
+		return path
+	}
+	return `"` + out.String() + `"`
+}
+
+// WriteChangedFiles stores the changed files as lines of status, path and
+// (for renames and copies) the old path, separated by tabs. Paths are quoted
+// like Git does (see quotePath), so tabs and newlines in them can't be mixed
+// up with the separators.
+func (r *Report) WriteChangedFiles(path string) error {
+	var out bytes.Buffer
+	for _, file := range r.ChangedFiles {
+		fields := []string{file.Status, quotePath(file.Path)}
+		if file.OldPath != "" {
+			fields = append(fields, quotePath(file.OldPath))
+		}
+		out.WriteString(strings.Join(fields, "\t") + "\n")
+	}
+	return writeAtomic(path, out.Bytes())
+}
+
+// WriteChangedFilesJSON stores the changed files as JSON, along with the
+// commits they were compared between
+func (r *Report) WriteChangedFilesJSON(path string) error {
+	changes := Changes{MergeBaseSHA: r.MergeBaseSHA, HeadSHA: r.PRHeadSHA, Files: r.ChangedFiles}
+	if changes.HeadSHA == "" {
+		// Pushes are compared with the commit that was checked out
+		changes.HeadSHA = r.HeadSHA
+	}
+	if changes.Files == nil {
+		changes.Files = []ChangedFile{}
+	}
+	bytes, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, append(bytes, '\n'))
+}
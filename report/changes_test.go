@@ -0,0 +1,113 @@
+package report
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testChanges = Report{
+	MergeBaseSHA: "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5",
+	PRHeadSHA:    "ace893fb2c9553a38a873fb03d0e21a406b351a1",
+	ChangedFiles: []ChangedFile{
+		{Status: "modified", Path: "README.md"},
+		{Status: "added", Path: "docs/with space.md"},
+		{Status: "renamed", Path: "new.go", OldPath: "old.go"},
+		{Status: "deleted", Path: "gone.txt"},
+	},
+}
+
+func TestWriteChangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "changed-files.txt")
+
+	changes := testChanges
+	changes.ChangedFiles = append(changes.ChangedFiles[:len(changes.ChangedFiles):len(changes.ChangedFiles)],
+		ChangedFile{Status: "renamed", Path: "tab\there.txt", OldPath: "new\nline.txt"})
+	if err = changes.WriteChangedFiles(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	bytes, _ := ioutil.ReadFile(path)
+	want := "modified\tREADME.md\n" +
+		"added\tdocs/with space.md\n" +
+		"renamed\tnew.go\told.go\n" +
+		"deleted\tgone.txt\n" +
+		"renamed\t\"tab\\there.txt\"\t\"new\\nline.txt\"\n"
+	if string(bytes) != want {
+		t.Errorf("Received the wrong changed files:\n%s\nwant:\n%s", bytes, want)
+	}
+}
+
+func TestQuotePath(t *testing.T) {
+	tests := map[string]string{
+		"README.md":          "README.md",
+		"docs/with space.md": "docs/with space.md",
+		"tab\there":          `"tab\there"`,
+		`say "hi".txt`:       `"say \"hi\".txt"`,
+		`back\slash`:         `"back\\slash"`,
+		"caf\u00e9.txt":      `"caf\303\251.txt"`,
+		"bell\x07":           `"bell\a"`,
+		"esc\x1b":            `"esc\033"`,
+	}
+	for path, want := range tests {
+		if got := quotePath(path); got != want {
+			t.Errorf("Received the wrong quoting for %q: %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestWriteChangedFilesJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "changed-files.json")
+
+	if err = testChanges.WriteChangedFilesJSON(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	bytes, _ := ioutil.ReadFile(path)
+	want := `{
+  "merge_base_sha": "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5",
+  "head_sha": "ace893fb2c9553a38a873fb03d0e21a406b351a1",
+  "files": [
+    {
+      "status": "modified",
+      "path": "README.md"
+    },
+    {
+      "status": "added",
+      "path": "docs/with space.md"
+    },
+    {
+      "status": "renamed",
+      "path": "new.go",
+      "old_path": "old.go"
+    },
+    {
+      "status": "deleted",
+      "path": "gone.txt"
+    }
+  ]
+}
+`
+	if string(bytes) != want {
+		t.Errorf("Received the wrong changed files:\n%s\nwant:\n%s", bytes, want)
+	}
+
+	empty := Report{}
+	if err = empty.WriteChangedFilesJSON(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	bytes, _ = ioutil.ReadFile(path)
+	want = "{\n  \"merge_base_sha\": \"\",\n  \"head_sha\": \"\",\n  \"files\": []\n}\n"
+	if string(bytes) != want {
+		t.Errorf("Received the wrong changed files:\n%s\nwant:\n%s", bytes, want)
+	}
+}
@@ -0,0 +1,32 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Env returns the variables describing the checkout, in a stable order
+func (r *Report) Env() [][2]string {
+	return [][2]string{
+		{"GIT_COMMIT", r.HeadSHA},
+		{"GIT_BASE_COMMIT", r.BaseSHA},
+		{"GIT_PR_HEAD", r.PRHeadSHA},
+		{"GIT_BRANCH", r.Branch},
+		{"GIT_REPO", r.Repo},
+	}
+}
+
+// WriteEnvFile stores the variables as KEY='value' lines that can be sourced by a shell
+func (r *Report) WriteEnvFile(path string) error {
+	var out bytes.Buffer
+	for _, pair := range r.Env() {
+		fmt.Fprintf(&out, "%s=%s\n", pair[0], ShellQuote(pair[1]))
+	}
+	return writeAtomic(path, out.Bytes())
+}
+
+// ShellQuote wraps a value in single quotes so that a POSIX shell reads it literally
+func ShellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkout.env")
+
+	r := Report{
+		Repo:      "testOrg/testRepo",
+		Branch:    "feature/it's $HOME",
+		BaseSHA:   "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5",
+		PRHeadSHA: "ace893fb2c9553a38a873fb03d0e21a406b351a1",
+		HeadSHA:   "302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+	}
+	if err = r.WriteEnvFile(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	bytes, _ := ioutil.ReadFile(path)
+	want := `GIT_COMMIT='302f5f5b48b9feee797a66c88811f1770bcb2dcf'
+GIT_BASE_COMMIT='1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5'
+GIT_PR_HEAD='ace893fb2c9553a38a873fb03d0e21a406b351a1'
+GIT_BRANCH='feature/it'\''s $HOME'
+GIT_REPO='testOrg/testRepo'
+`
+	if string(bytes) != want {
+		t.Errorf("Received the wrong env file:\n%s\nwant\n%s", bytes, want)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"":          "''",
+		"master":    "'master'",
+		"a b":       "'a b'",
+		"it's":      `'it'\''s'`,
+		"$(rm -rf)": "'$(rm -rf)'",
+	}
+	for input, want := range tests {
+		if got := ShellQuote(input); got != want {
+			t.Errorf("ShellQuote(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
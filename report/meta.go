@@ -0,0 +1,76 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// MetaKey is the key in the Screwdriver build meta that we store our values under
+const MetaKey = "bookend"
+
+// Meta is what we expose to later steps via the Screwdriver build meta
+type Meta struct {
+	SHA           string             `json:"sha"`
+	BaseSHA       string             `json:"base_sha"`
+	PRHeadSHA     string             `json:"pr_head,omitempty"`
+	ChangedFiles  int                `json:"changed_files"`
+	Durations     map[string]float64 `json:"durations,omitempty"`
+	BytesReceived int64              `json:"bytes_received,omitempty"`
+	SkipReason    string             `json:"skip_reason,omitempty"`
+	Repos         map[string]string  `json:"repos,omitempty"`
+}
+
+// Meta returns the values that should be stored in the build meta
+func (r *Report) Meta() Meta {
+	meta := Meta{
+		SHA:           r.HeadSHA,
+		BaseSHA:       r.BaseSHA,
+		PRHeadSHA:     r.PRHeadSHA,
+		ChangedFiles:  len(r.ChangedFiles),
+		BytesReceived: r.BytesReceived(),
+		SkipReason:    r.SkipReason,
+	}
+	if len(r.Repos) > 0 {
+		meta.Repos = map[string]string{}
+		for _, repo := range r.Repos {
+			meta.Repos[repo.Path] = repo.SHA
+		}
+	}
+	if len(r.Steps) > 0 {
+		meta.Durations = map[string]float64{}
+		for _, step := range r.Steps {
+			meta.Durations[step.Name] = step.Duration
+		}
+	}
+	return meta
+}
+
+// WriteMeta merges our values into the Screwdriver meta JSON file, keeping
+// whatever other steps have already stored there
+func (r *Report) WriteMeta(path string) error {
+	meta := map[string]interface{}{}
+
+	bytes, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case len(bytes) > 0:
+		if err = json.Unmarshal(bytes, &meta); err != nil {
+			return fmt.Errorf("Unable to parse %s: %v", path, err)
+		}
+	}
+	if meta == nil {
+		meta = map[string]interface{}{}
+	}
+
+	meta[MetaKey] = r.Meta()
+
+	bytes, err = json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, bytes)
+}
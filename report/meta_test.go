@@ -0,0 +1,100 @@
+package report
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteMeta(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "meta.json")
+
+	r := Report{
+		BaseSHA: "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5",
+		HeadSHA: "302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+	}
+
+	if err = r.WriteMeta(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	bytes, _ := ioutil.ReadFile(path)
+	want := `{"bookend":{"sha":"302f5f5b48b9feee797a66c88811f1770bcb2dcf",` +
+		`"base_sha":"1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5","changed_files":0}}`
+	if string(bytes) != want {
+		t.Errorf("Received the wrong meta: %s, want %s", bytes, want)
+	}
+}
+
+func TestWriteMetaMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "meta.json")
+	ioutil.WriteFile(path, []byte(`{"bookend":"old","foo":{"bar":1}}`), 0644)
+
+	r := Report{
+		BaseSHA:      "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5",
+		HeadSHA:      "302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+		PRHeadSHA:    "ace893fb2c9553a38a873fb03d0e21a406b351a1",
+		ChangedFiles: []ChangedFile{{Status: "added", Path: "README.md"}},
+		Steps: []Step{
+			{Name: "clone", Duration: 2.5, Bytes: 1024},
+			{Name: "merge", Duration: 0.5},
+		},
+	}
+
+	if err = r.WriteMeta(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	bytes, _ := ioutil.ReadFile(path)
+	want := `{"bookend":{"sha":"302f5f5b48b9feee797a66c88811f1770bcb2dcf",` +
+		`"base_sha":"1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5",` +
+		`"pr_head":"ace893fb2c9553a38a873fb03d0e21a406b351a1","changed_files":1,` +
+		`"durations":{"clone":2.5,"merge":0.5},"bytes_received":1024},"foo":{"bar":1}}`
+	if string(bytes) != want {
+		t.Errorf("Received the wrong meta: %s, want %s", bytes, want)
+	}
+}
+
+func TestWriteMetaInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "meta.json")
+	ioutil.WriteFile(path, []byte(`not json`), 0644)
+
+	r := Report{}
+	err = r.WriteMeta(path)
+	if err == nil {
+		t.Errorf("Expected an error merging into invalid JSON")
+	}
+}
+
+func TestMetaRepos(t *testing.T) {
+	r := Report{
+		HeadSHA: "302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+		Repos: []Repo{
+			{Host: "github.com", Repo: "testOrg/protos", Path: "protos", SHA: "ace893fb2c9553a38a873fb03d0e21a406b351a1"},
+			{Host: "github.com", Repo: "testOrg/config", Path: "shared/config", SHA: "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5"},
+		},
+	}
+
+	want := map[string]string{
+		"protos":        "ace893fb2c9553a38a873fb03d0e21a406b351a1",
+		"shared/config": "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5",
+	}
+	if got := r.Meta().Repos; !reflect.DeepEqual(got, want) {
+		t.Errorf("Received the wrong repos: %v, want %v", got, want)
+	}
+}
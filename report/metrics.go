@@ -0,0 +1,70 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// metric is a gauge in the Prometheus text format
+type metric struct {
+	name   string
+	help   string
+	values []metricValue
+}
+
+type metricValue struct {
+	labels [][2]string
+	value  float64
+}
+
+// labelEscaper escapes label values the way the Prometheus text format needs
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Metrics returns the checkout as Prometheus text format gauges, labelled
+// by host and repo
+func (r *Report) Metrics() string {
+	labels := [][2]string{{"host", r.Host}, {"repo", r.Repo}}
+	gauge := func(value float64) []metricValue {
+		return []metricValue{{labels, value}}
+	}
+
+	success := 0.0
+	if r.Success {
+		success = 1
+	}
+	steps := []metricValue{}
+	for _, step := range r.Steps {
+		steps = append(steps, metricValue{append(labels[:2:2], [2]string{"step", step.Name}), step.Duration})
+	}
+
+	metrics := []metric{
+		{"bookend_checkout_success", "Whether the checkout succeeded (1) or failed (0)", gauge(success)},
+		{"bookend_checkout_exit_code", "Exit code of the checkout, see the README for what each means", gauge(float64(r.ExitCode))},
+		{"bookend_checkout_duration_seconds", "Time taken by the whole checkout", gauge(r.Duration())},
+		{"bookend_checkout_step_duration_seconds", "Time taken by each step of the checkout", steps},
+		{"bookend_checkout_received_bytes", "Bytes downloaded by the checkout, when Git reported it", gauge(float64(r.BytesReceived()))},
+		{"bookend_checkout_timestamp_seconds", "When the checkout finished, as a Unix timestamp", gauge(float64(timeNow().Unix()))},
+	}
+
+	var out bytes.Buffer
+	for _, m := range metrics {
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s gauge\n", m.name, m.help, m.name)
+		for _, v := range m.values {
+			pairs := make([]string, len(v.labels))
+			for i, label := range v.labels {
+				pairs[i] = fmt.Sprintf(`%s="%s"`, label[0], labelEscaper.Replace(label[1]))
+			}
+			fmt.Fprintf(&out, "%s{%s} %s\n", m.name, strings.Join(pairs, ","), strconv.FormatFloat(v.value, 'f', -1, 64))
+		}
+	}
+	return out.String()
+}
+
+// WriteMetrics stores the metrics for the node_exporter textfile collector.
+// The file is replaced atomically and the temporary file doesn't end in
+// .prom, so the collector never reads half of it.
+func (r *Report) WriteMetrics(path string) error {
+	return writeAtomic(path, []byte(r.Metrics()))
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	timeNow = mockClock(time.Date(2017, 8, 1, 10, 0, 0, 0, time.UTC))
+	defer func() { timeNow = time.Now }()
+
+	r := Report{
+		Host: "github.com",
+		Repo: `testOrg/"test"Repo`,
+		Steps: []Step{
+			{Name: "clone", Duration: 2.5, Bytes: 1394606},
+			{Name: "merge", Duration: 0.25},
+		},
+		ExitCode: 7,
+	}
+
+	labels := `host="github.com",repo="testOrg/\"test\"Repo"`
+	want := "# HELP bookend_checkout_success Whether the checkout succeeded (1) or failed (0)\n" +
+		"# TYPE bookend_checkout_success gauge\n" +
+		"bookend_checkout_success{" + labels + "} 0\n" +
+		"# HELP bookend_checkout_exit_code Exit code of the checkout, see the README for what each means\n" +
+		"# TYPE bookend_checkout_exit_code gauge\n" +
+		"bookend_checkout_exit_code{" + labels + "} 7\n" +
+		"# HELP bookend_checkout_duration_seconds Time taken by the whole checkout\n" +
+		"# TYPE bookend_checkout_duration_seconds gauge\n" +
+		"bookend_checkout_duration_seconds{" + labels + "} 2.75\n" +
+		"# HELP bookend_checkout_step_duration_seconds Time taken by each step of the checkout\n" +
+		"# TYPE bookend_checkout_step_duration_seconds gauge\n" +
+		"bookend_checkout_step_duration_seconds{" + labels + `,step="clone"} 2.5` + "\n" +
+		"bookend_checkout_step_duration_seconds{" + labels + `,step="merge"} 0.25` + "\n" +
+		"# HELP bookend_checkout_received_bytes Bytes downloaded by the checkout, when Git reported it\n" +
+		"# TYPE bookend_checkout_received_bytes gauge\n" +
+		"bookend_checkout_received_bytes{" + labels + "} 1394606\n" +
+		"# HELP bookend_checkout_timestamp_seconds When the checkout finished, as a Unix timestamp\n" +
+		"# TYPE bookend_checkout_timestamp_seconds gauge\n" +
+		"bookend_checkout_timestamp_seconds{" + labels + "} 1501581600\n"
+	if got := r.Metrics(); got != want {
+		t.Errorf("Received the wrong metrics:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bookend.prom")
+
+	r := Report{Host: "github.com", Repo: "testOrg/testRepo", Success: true}
+	if err = r.WriteMetrics(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	bytes, _ := ioutil.ReadFile(path)
+	if string(bytes) != r.Metrics() {
+		t.Errorf("Received the wrong metrics file: %s", bytes)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Expected only the metrics file to remain, got %d files", len(files))
+	}
+}
@@ -0,0 +1,138 @@
+package report
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var timeNow = time.Now
+
+// Step is the timing of a single stage of the checkout
+type Step struct {
+	Name     string    `json:"name"`
+	Started  time.Time `json:"started"`
+	Duration float64   `json:"duration_seconds"`
+	Bytes    int64     `json:"bytes_received,omitempty"`
+	Commands []string  `json:"commands,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Repo is a repository checked out from --manifest
+type Repo struct {
+	Host string `json:"host"`
+	Repo string `json:"repo"`
+	Ref  string `json:"ref,omitempty"`
+	Path string `json:"path"`
+	SHA  string `json:"sha"`
+}
+
+// Report is everything we learned about the checkout
+type Report struct {
+	Host          string        `json:"host"`
+	Repo          string        `json:"repo"`
+	Branch        string        `json:"branch"`
+	BaseSHA       string        `json:"base_sha,omitempty"`
+	PullRequest   int           `json:"pull_request,omitempty"`
+	PRHeadSHA     string        `json:"pr_head_sha,omitempty"`
+	MergeBaseSHA  string        `json:"merge_base_sha,omitempty"`
+	HeadSHA       string        `json:"head_sha,omitempty"`
+	MergeStrategy string        `json:"merge_strategy,omitempty"`
+	ChangedFiles  []ChangedFile `json:"changed_files,omitempty"`
+	Repos         []Repo        `json:"repos,omitempty"`
+	GitVersion    string        `json:"git_version,omitempty"`
+	Steps         []Step        `json:"steps"`
+	Success       bool          `json:"success"`
+	ExitCode      int           `json:"exit_code"`
+	SkipReason    string        `json:"skip_reason,omitempty"`
+	Error         string        `json:"error,omitempty"`
+
+	current *Step
+}
+
+// StartStep finishes the running step (if any) and starts timing a new one
+func (r *Report) StartStep(name string) {
+	r.EndStep()
+	r.current = &Step{Name: name, Started: timeNow()}
+}
+
+// EndStep finishes timing the running step
+func (r *Report) EndStep() {
+	if r.current == nil {
+		return
+	}
+	r.current.Duration = timeNow().Sub(r.current.Started).Seconds()
+	r.Steps = append(r.Steps, *r.current)
+	r.current = nil
+}
+
+// AddBytes records that the running step downloaded n more bytes
+func (r *Report) AddBytes(n int64) {
+	if r.current != nil {
+		r.current.Bytes += n
+	}
+}
+
+// Fail records why the run failed, against the running step (if any)
+func (r *Report) Fail(message string) {
+	r.Error = message
+	if r.current != nil {
+		r.current.Error = message
+	}
+}
+
+// AddCommand records a Git command run by the running step
+func (r *Report) AddCommand(command string) {
+	if r.current != nil {
+		r.current.Commands = append(r.current.Commands, command)
+	}
+}
+
+// Duration is how long all of the finished steps took together
+func (r *Report) Duration() float64 {
+	total := 0.0
+	for _, step := range r.Steps {
+		total += step.Duration
+	}
+	return total
+}
+
+// BytesReceived is how much all of the finished steps downloaded together
+func (r *Report) BytesReceived() int64 {
+	var total int64
+	for _, step := range r.Steps {
+		total += step.Bytes
+	}
+	return total
+}
+
+// WriteFile stores the report as JSON, replacing the file atomically
+func (r *Report) WriteFile(path string) error {
+	bytes, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, append(bytes, '\n'))
+}
+
+func writeAtomic(path string, contents []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".bookend-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func mockClock(times ...time.Time) func() time.Time {
+	index := 0
+	return func() time.Time {
+		now := times[index]
+		if index < len(times)-1 {
+			index++
+		}
+		return now
+	}
+}
+
+func TestSteps(t *testing.T) {
+	start := time.Date(2017, 8, 1, 10, 0, 0, 0, time.UTC)
+	timeNow = mockClock(start, start.Add(2*time.Second), start.Add(2*time.Second), start.Add(2500*time.Millisecond))
+	defer func() { timeNow = time.Now }()
+
+	r := Report{}
+	r.AddBytes(5)
+	r.StartStep("clone")
+	r.AddBytes(1000)
+	r.AddBytes(24)
+	r.AddCommand("git clone https://github.com/testOrg/testRepo.git")
+	r.StartStep("reset")
+	r.Fail("Failed command")
+	r.EndStep()
+	r.EndStep()
+
+	want := []Step{
+		{Name: "clone", Started: start, Duration: 2, Bytes: 1024, Commands: []string{"git clone https://github.com/testOrg/testRepo.git"}},
+		{Name: "reset", Started: start.Add(2 * time.Second), Duration: 0.5, Error: "Failed command"},
+	}
+	if !reflect.DeepEqual(r.Steps, want) {
+		t.Errorf("Received the wrong steps: %v, want %v", r.Steps, want)
+	}
+	if r.Error != "Failed command" {
+		t.Errorf("Received the wrong error: %q", r.Error)
+	}
+	if r.Duration() != 2.5 || r.BytesReceived() != 1024 {
+		t.Errorf("Received the wrong totals: %v, %v, want 2.5, 1024", r.Duration(), r.BytesReceived())
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "result.json")
+
+	r := Report{
+		Host:          "github.com",
+		Repo:          "testOrg/testRepo",
+		Branch:        "master",
+		BaseSHA:       "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5",
+		HeadSHA:       "302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+		MergeStrategy: "reset",
+		Steps:         []Step{},
+		Success:       true,
+	}
+	if err = r.WriteFile(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err = json.Unmarshal(bytes, &got); err != nil {
+		t.Fatalf("Unable to parse %s: %v", bytes, err)
+	}
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("Received the wrong report: %+v, want %+v", got, r)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Expected only the result file to remain, got %d files", len(files))
+	}
+}
+
+func TestWriteFileFail(t *testing.T) {
+	r := Report{}
+	err := r.WriteFile("/does/not/exist/result.json")
+	if err == nil {
+		t.Errorf("Expected an error writing to a missing directory")
+	}
+}
@@ -0,0 +1,41 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Summary is a table of how long each finished step took and what it
+// downloaded, with the totals at the bottom
+func (r *Report) Summary() string {
+	var out bytes.Buffer
+	row := func(name, duration, received string) {
+		line := fmt.Sprintf("%-14s %8s %12s", name, duration, received)
+		out.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	row("Step", "Duration", "Received")
+	for _, step := range r.Steps {
+		row(step.Name, fmt.Sprintf("%.1fs", step.Duration), FormatBytes(step.Bytes))
+	}
+	row("total", fmt.Sprintf("%.1fs", r.Duration()), FormatBytes(r.BytesReceived()))
+	return out.String()
+}
+
+// FormatBytes shows a size the way Git does (e.g. 1.33 MiB), or nothing for 0
+func FormatBytes(n int64) string {
+	switch {
+	case n <= 0:
+		return ""
+	case n == 1:
+		return "1 byte"
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
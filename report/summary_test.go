@@ -0,0 +1,39 @@
+package report
+
+import "testing"
+
+func TestSummary(t *testing.T) {
+	r := Report{Steps: []Step{
+		{Name: "clone", Duration: 2.14, Bytes: 1394606},
+		{Name: "config", Duration: 0.01},
+		{Name: "fetch", Duration: 0.4, Bytes: 230},
+		{Name: "merge", Duration: 0.12},
+	}}
+
+	want := "" +
+		"Step           Duration     Received\n" +
+		"clone              2.1s     1.33 MiB\n" +
+		"config             0.0s\n" +
+		"fetch              0.4s    230 bytes\n" +
+		"merge              0.1s\n" +
+		"total              2.7s     1.33 MiB\n"
+	if got := r.Summary(); got != want {
+		t.Errorf("Received the wrong summary:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:          "",
+		1:          "1 byte",
+		1023:       "1023 bytes",
+		4608:       "4.50 KiB",
+		1394606:    "1.33 MiB",
+		2147483648: "2.00 GiB",
+	}
+	for n, want := range tests {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%v) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+)
+
+var notifySignals = signal.Notify
+var gitInterrupt = git.Interrupt
+
+// stoppedBy is the signal that cancelled us (if any), waiting are the
+// requests to give up on the next one
+var stoppedBy struct {
+	sync.Mutex
+	signal  os.Signal
+	waiting []context.CancelFunc
+}
+
+// partialDir is a checkout that isn't finished yet, emptied if we're cancelled
+var partialDir string
+
+// handleSignals cancels the run on SIGINT or SIGTERM, by stopping the Git
+// command that's running so that the step it's in fails
+func handleSignals() {
+	signals := make(chan os.Signal, 2)
+	notifySignals(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			interrupted(sig)
+		}
+	}()
+}
+
+// interrupted passes the first signal on to Git, and kills it on the next one.
+// Either way, anything waiting on untilSignal is cancelled.
+func interrupted(sig os.Signal) {
+	stoppedBy.Lock()
+	first := stoppedBy.signal == nil
+	if first {
+		stoppedBy.signal = sig
+	}
+	waiting := stoppedBy.waiting
+	stoppedBy.waiting = nil
+	stoppedBy.Unlock()
+
+	for _, cancel := range waiting {
+		cancel()
+	}
+
+	if !first {
+		sig = os.Kill
+	}
+	gitInterrupt(sig)
+}
+
+func stopSignal() os.Signal {
+	stoppedBy.Lock()
+	defer stoppedBy.Unlock()
+	return stoppedBy.signal
+}
+
+// untilSignal is a context for a request (like an HTTP call) that's cancelled
+// by the next SIGINT or SIGTERM, so that we don't hang on it once cancelled
+func untilSignal() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stoppedBy.Lock()
+	stoppedBy.waiting = append(stoppedBy.waiting, cancel)
+	stoppedBy.Unlock()
+	return ctx, cancel
+}
+
+// stopped fails the run if a signal has arrived, for the steps that don't run
+// Git (which stops on its own)
+func stopped() bool {
+	if stopSignal() == nil {
+		return false
+	}
+	fail(exitInterrupted, "")
+	return true
+}
+
+// removeContents empties dir, which is left in place in case it was made for us
+func removeContents(dir string) {
+	files, _ := ioutil.ReadDir(dir)
+	for _, file := range files {
+		os.RemoveAll(filepath.Join(dir, file.Name()))
+	}
+}
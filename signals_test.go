@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+	"github.com/stjohnjohnson/bookend-scm-github/github"
+)
+
+// mainUntilExit runs main until it exits, like a real exit would
+func mainUntilExit(want int, t *testing.T) {
+	exited := []int{}
+	osExit = func(code int) {
+		exited = append(exited, code)
+		panic(exited)
+	}
+	defer func() {
+		recover()
+		if len(exited) != 1 || exited[0] != want {
+			t.Errorf("Received the wrong exit codes: %v, want [%d]", exited, want)
+		}
+	}()
+	main()
+}
+
+// cloneInterrupted is a clone that gets cancelled half way through
+func cloneInterrupted(dir string, t *testing.T) func(...string) error {
+	return func(args ...string) error {
+		if err := ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("half"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		interrupted(syscall.SIGTERM)
+		return errors.New("Command failed: signal: terminated")
+	}
+}
+
+func TestMainInterrupted(t *testing.T) {
+	defer restoreMocks()
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	forwarded := []os.Signal{}
+	gitInterrupt = func(sig os.Signal) error {
+		forwarded = append(forwarded, sig)
+		return nil
+	}
+	defer func() { gitInterrupt = git.Interrupt }()
+
+	executeStream = cloneInterrupted(dir, t)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n",
+		"Git Client:\tv1.2.3\n",
+		"\n☛ Cloning github.com/testOrg/testRepo, on branch master\n",
+		"Removing partial checkout from " + dir + "\n",
+		"Stopped by signal: terminated\n",
+	}, t)
+	osChdir = func(string) error { return nil }
+	getGitVersion = mockVersion
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		onBranch(args)
+		args.TargetDir = dir
+	})
+	mainUntilExit(exitInterrupted, t)
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 0 {
+		t.Errorf("Expected the partial checkout to be removed, found %d files", len(files))
+	}
+	if len(forwarded) != 1 || forwarded[0] != syscall.SIGTERM {
+		t.Errorf("Expected SIGTERM to be passed on to Git, got %v", forwarded)
+	}
+
+	interrupted(syscall.SIGTERM)
+	if len(forwarded) != 2 || forwarded[1] != os.Kill {
+		t.Errorf("Expected Git to be killed on the second signal, got %v", forwarded)
+	}
+}
+
+func TestMainInterruptedKeepOnFailure(t *testing.T) {
+	defer restoreMocks()
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gitInterrupt = func(os.Signal) error { return nil }
+	defer func() { gitInterrupt = git.Interrupt }()
+
+	executeStream = cloneInterrupted(dir, t)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n",
+		"Git Client:\tv1.2.3\n",
+		"\n☛ Cloning github.com/testOrg/testRepo, on branch master\n",
+		"Stopped by signal: terminated\n",
+	}, t)
+	osChdir = func(string) error { return nil }
+	getGitVersion = mockVersion
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		onBranch(args)
+		args.TargetDir = dir
+		args.KeepOnFailure = true
+	})
+	mainUntilExit(exitInterrupted, t)
+
+	if _, err := os.Stat(filepath.Join(dir, "README.md")); err != nil {
+		t.Errorf("Expected the partial checkout to be kept, got %v", err)
+	}
+}
+
+func TestUntilSignal(t *testing.T) {
+	defer restoreMocks()
+	gitInterrupt = func(os.Signal) error { return nil }
+	defer func() { gitInterrupt = git.Interrupt }()
+
+	ctx, cancel := untilSignal()
+	defer cancel()
+	interrupted(syscall.SIGINT)
+	if ctx.Err() == nil {
+		t.Errorf("Expected the context to be cancelled by the signal")
+	}
+
+	// Requests made after the first signal only give up on the next one
+	ctx, cancel = untilSignal()
+	defer cancel()
+	if ctx.Err() != nil {
+		t.Errorf("Expected the context to wait for the next signal")
+	}
+	interrupted(syscall.SIGINT)
+	if ctx.Err() == nil {
+		t.Errorf("Expected the context to be cancelled by the second signal")
+	}
+}
+
+func TestMainInterruptedReportingStatus(t *testing.T) {
+	defer restoreMocks()
+	defer func() { reportStatus = func(string, string) {} }()
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gitInterrupt = func(os.Signal) error { return nil }
+	defer func() { gitInterrupt = git.Interrupt }()
+
+	// The signal arrives after the last Git command, while GitHub is slow to
+	// answer, which shouldn't hold up the run
+	var lock sync.Mutex
+	states := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var status github.Status
+		json.NewDecoder(r.Body).Decode(&status)
+		lock.Lock()
+		states = append(states, status.State+": "+status.Description)
+		lock.Unlock()
+		if status.State == github.StateSuccess {
+			interrupted(syscall.SIGTERM)
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	executeStream = func(args ...string) error {
+		return ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("done"), 0644)
+	}
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osChdir = func(string) error { return nil }
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	isAncestor = func(string, string) (bool, error) { return true, nil }
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		onBranch(args)
+		args.TargetDir = dir
+		args.ReportStatus = true
+		args.GitHubAPIURL = server.URL
+	})
+	mainUntilExit(exitInterrupted, t)
+
+	want := []string{
+		"pending: Checking out master",
+		"success: Checked out 1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5",
+		"failure: Cancelled",
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if strings.Join(states, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Received the wrong statuses: %q, want %q", states, want)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 0 {
+		t.Errorf("Expected the checkout to be removed, found %d files", len(files))
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+)
+
+var signatureStatuses = map[string]string{
+	"B": "bad signature",
+	"U": "signed by a key that isn't allowed",
+	"X": "expired signature",
+	"Y": "signed by an expired key",
+	"R": "signed by a revoked key",
+	"E": "signed by a key that isn't allowed",
+	"N": "not signed",
+}
+
+// verifySignatures makes sure every commit in revisions (as accepted by git
+// log) is signed by one of the allowed keys
+func verifySignatures(args arguments.CommandArgs, revisions ...string) error {
+	// Without --gpg-keyring GnuPG gets an empty home, so the keys the agent
+	// happens to trust can't pass for allowed ones
+	home, err := gpgHome(args.GPGKeyring)
+	if err != nil {
+		return fmt.Errorf("Unable to use GPG keyring: %v", err)
+	}
+	defer os.RemoveAll(home)
+	gitSetenv("GNUPGHOME", home)
+
+	signatures, err := getSignatures(args.AllowedSigners, revisions...)
+	if err != nil {
+		return err
+	}
+
+	for _, signature := range signatures {
+		if signature.Status == "G" {
+			continue
+		}
+		problem, ok := signatureStatuses[signature.Status]
+		if !ok {
+			problem = fmt.Sprintf("signature status %q", signature.Status)
+		}
+		details := []string{}
+		if signature.Signer != "" {
+			details = append(details, fmt.Sprintf("signer %q", signature.Signer))
+		}
+		if signature.Key != "" {
+			details = append(details, "key "+signature.Key)
+		}
+		if len(details) > 0 {
+			problem += " (" + strings.Join(details, ", ") + ")"
+		}
+		return fmt.Errorf("Commit %s is %s", signature.SHA, problem)
+	}
+	return nil
+}
+
+// gpgHome makes a GnuPG home directory that only knows the keys in keyring
+// (already absolute, so it's never read from the checkout), and trusts all of
+// them, so a good signature means an allowed signer. Without a keyring it
+// knows no keys at all.
+func gpgHome(keyring string) (string, error) {
+	home, err := ioutil.TempDir("", "bookend-gnupg")
+	if err != nil || keyring == "" {
+		return home, err
+	}
+	conf := fmt.Sprintf("no-default-keyring\nkeyring %s\ntrust-model always\n", keyring)
+	if err = ioutil.WriteFile(filepath.Join(home, "gpg.conf"), []byte(conf), 0600); err != nil {
+		os.RemoveAll(home)
+		return "", err
+	}
+	return home, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+)
+
+// sshSigningVersion is a Git that can check SSH signatures
+func sshSigningVersion() (git.Version, error) {
+	return git.ParseVersion("2.39.5")
+}
+
+func TestMainRequireSigned(t *testing.T) {
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"fetch --progress origin pull/15/head:pr",
+		"merge --no-edit ace893fb2c9553a38a873fb03d0e21a406b351a1",
+	}, t, false)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n",
+		"Git Client:\tv2.39.5\n",
+		"\n☛ Cloning github.com/testOrg/testRepo, on branch master\n",
+		"\n☛ Saving local git config\n",
+		"\n☛ Fetching PR 15\n",
+		"\n☛ Checking commit signatures\n",
+		"\n☛ Merging with master\n",
+		"\n☛ Checked out 302f5f5b48b9feee797a66c88811f1770bcb2dcf",
+		"\n✓ Done\n",
+		mockSummary(0, "clone", "config", "fetch", "signatures", "merge"),
+	}, t)
+	osExit = mockExit(0, t)
+	getGitVersion = sshSigningVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
+	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
+	getSignatures = func(allowedSigners string, revisions ...string) ([]git.Signature, error) {
+		if allowedSigners != "/etc/bookend/allowed_signers" {
+			t.Errorf("Received the wrong allowed signers: %v", allowedSigners)
+		}
+		want := "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5..ace893fb2c9553a38a873fb03d0e21a406b351a1"
+		if strings.Join(revisions, " ") != want {
+			t.Errorf("Received the wrong revisions: %v, want %v", revisions, want)
+		}
+		return []git.Signature{
+			{SHA: "ace893fb2c9553a38a873fb03d0e21a406b351a1", Status: "G", Signer: "stjohn@example.com"},
+			{SHA: "302f5f5b48b9feee797a66c88811f1770bcb2dcf", Status: "G", Signer: "stjohn@example.com"},
+		}, nil
+	}
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.PullRequest = 15
+		args.RequireSigned = true
+		args.AllowedSigners = "/etc/bookend/allowed_signers"
+	})
+	main()
+}
+
+func TestMainRequireSignedUnsigned(t *testing.T) {
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"fetch --progress origin pull/15/head:pr",
+	}, t, false)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n",
+		"Git Client:\tv2.39.5\n",
+		"\n☛ Cloning github.com/testOrg/testRepo, on branch master\n",
+		"\n☛ Saving local git config\n",
+		"\n☛ Fetching PR 15\n",
+		"\n☛ Checking commit signatures\n",
+		"Commit 302f5f5b48b9feee797a66c88811f1770bcb2dcf is signed by a key that isn't allowed (key SHA256:abc)\n",
+	}, t)
+	exited := []int{}
+	osExit = func(code int) {
+		exited = append(exited, code)
+		// Stop the checkout here, like a real exit would
+		panic(exited)
+	}
+	getGitVersion = sshSigningVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
+	getSignatures = func(string, ...string) ([]git.Signature, error) {
+		return []git.Signature{
+			{SHA: "ace893fb2c9553a38a873fb03d0e21a406b351a1", Status: "G", Signer: "stjohn@example.com"},
+			{SHA: "302f5f5b48b9feee797a66c88811f1770bcb2dcf", Status: "U", Key: "SHA256:abc"},
+		}, nil
+	}
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.PullRequest = 15
+		args.RequireSigned = true
+		args.AllowedSigners = "/etc/bookend/allowed_signers"
+	})
+
+	defer func() {
+		recover()
+		if len(exited) != 1 || exited[0] != 8 {
+			t.Errorf("Received the wrong exit codes: %v, want [8]", exited)
+		}
+	}()
+	main()
+}
+
+func TestGPGHome(t *testing.T) {
+	home, err := gpgHome("/etc/bookend/allowed.gpg")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer os.RemoveAll(home)
+
+	conf, _ := ioutil.ReadFile(filepath.Join(home, "gpg.conf"))
+	want := "no-default-keyring\nkeyring /etc/bookend/allowed.gpg\ntrust-model always\n"
+	if string(conf) != want {
+		t.Errorf("Received the wrong gpg.conf: %q, want %q", conf, want)
+	}
+}
+
+func TestVerifySignaturesKeyring(t *testing.T) {
+	var gnupgHome string
+	gitSetenv = func(key, value string) {
+		if key == "GNUPGHOME" {
+			gnupgHome = value
+		}
+	}
+	defer restoreMocks()
+	getSignatures = func(allowedSigners string, revisions ...string) ([]git.Signature, error) {
+		if _, err := os.Stat(filepath.Join(gnupgHome, "gpg.conf")); err != nil {
+			t.Errorf("Expected the GnuPG home to be ready: %v", err)
+		}
+		return []git.Signature{{SHA: "302f5f5b48b9feee797a66c88811f1770bcb2dcf", Status: "N"}}, nil
+	}
+
+	err := verifySignatures(arguments.CommandArgs{GPGKeyring: "allowed.gpg"}, "--max-count=1", "302f5f5b48b9feee797a66c88811f1770bcb2dcf")
+
+	want := `Commit 302f5f5b48b9feee797a66c88811f1770bcb2dcf is not signed`
+	if err == nil || err.Error() != want {
+		t.Errorf("Received the wrong error: %v, want %v", err, want)
+	}
+	if _, err = os.Stat(gnupgHome); !os.IsNotExist(err) {
+		t.Errorf("Expected the GnuPG home to be removed")
+	}
+}
+
+func TestVerifySignaturesNoKeyring(t *testing.T) {
+	var gnupgHome string
+	gitSetenv = func(key, value string) {
+		if key == "GNUPGHOME" {
+			gnupgHome = value
+		}
+	}
+	defer restoreMocks()
+	getSignatures = func(allowedSigners string, revisions ...string) ([]git.Signature, error) {
+		// Not the agent's own keyring, which would make its keys good signers
+		files, err := ioutil.ReadDir(gnupgHome)
+		if gnupgHome == "" || err != nil || len(files) > 0 {
+			t.Errorf("Expected an empty GnuPG home, got %q: %v %v", gnupgHome, files, err)
+		}
+		return []git.Signature{{SHA: "302f5f5b48b9feee797a66c88811f1770bcb2dcf", Status: "E", Key: "4F1A2B3C"}}, nil
+	}
+
+	err := verifySignatures(arguments.CommandArgs{AllowedSigners: "/etc/bookend/allowed_signers"}, "--max-count=1", "302f5f5b48b9feee797a66c88811f1770bcb2dcf")
+
+	want := `Commit 302f5f5b48b9feee797a66c88811f1770bcb2dcf is signed by a key that isn't allowed (key 4F1A2B3C)`
+	if err == nil || err.Error() != want {
+		t.Errorf("Received the wrong error: %v, want %v", err, want)
+	}
+	if _, err = os.Stat(gnupgHome); !os.IsNotExist(err) {
+		t.Errorf("Expected the GnuPG home to be removed")
+	}
+}
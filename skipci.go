@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+)
+
+// defaultSkipCIMarkers are what --skip-ci looks for without --skip-ci-markers
+var defaultSkipCIMarkers = []string{"[skip ci]", "[ci skip]"}
+
+// skipMarker returns the first of the markers in the commit message (ignoring case)
+func skipMarker(message string, markers []string) string {
+	message = strings.ToLower(message)
+	for _, marker := range markers {
+		if strings.Contains(message, strings.ToLower(marker)) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// skipCI stops the checkout if --skip-ci is used and one of the commits in
+// revisions (all of a pull request's) asks not to be built, returning whether
+// it did
+func skipCI(args arguments.CommandArgs, revisions ...string) bool {
+	if !args.SkipCI {
+		return false
+	}
+	markers := args.SkipCIMarkers
+	if len(markers) == 0 {
+		markers = defaultSkipCIMarkers
+	}
+	if args.Plan {
+		fmtPrint(greenColor(fmt.Sprintf("\n☛ Not checked by the plan: stopping with exit code %d if a commit message has %s\n", exitSkipCI, strings.Join(markers, ", "))))
+		return false
+	}
+
+	messages, err := getCommitMessages(revisions...)
+	if err != nil {
+		fail(exitSHANotFound, fmt.Sprintf("%v\n", err))
+		return true
+	}
+	for _, message := range messages {
+		if marker := skipMarker(message.Message, markers); marker != "" {
+			skip(exitSkipCI, fmt.Sprintf("Skipping CI, the message of %s has %s", message.SHA, marker))
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+)
+
+func TestSkipMarker(t *testing.T) {
+	tests := map[string]string{
+		"Fix the docs [skip ci]":          "[skip ci]",
+		"Fix the docs\n\n[CI SKIP]":       "[ci skip]",
+		"Fix the docs":                    "",
+		"Fix the docs, skip ci next time": "",
+	}
+	for message, want := range tests {
+		if got := skipMarker(message, defaultSkipCIMarkers); got != want {
+			t.Errorf("Received the wrong marker for %q: %q, want %q", message, got, want)
+		}
+	}
+	if got := skipMarker("WIP: [no build]", []string{"[No Build]"}); got != "[No Build]" {
+		t.Errorf("Received the wrong marker: %q, want %q", got, "[No Build]")
+	}
+}
+
+func TestMainSkipCI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bookend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	resultPath := filepath.Join(dir, "result.json")
+
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+	}, t, false)
+	fmtPrint = mockPrint([]string{
+		"Bookend:\tv1.0.0\n",
+		"Git Client:\tv1.2.3\n",
+		"\n☛ Cloning github.com/testOrg/testRepo, on branch master\n",
+		"\n☛ Saving local git config\n",
+		"\n✓ Skipping CI, the message of ace893fb2c9553a38a873fb03d0e21a406b351a1 has [ci skip]\n",
+	}, t)
+	exited := exitOK
+	osExit = func(code int) { exited = code }
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getCommitMessages = func(revisions ...string) ([]git.CommitMessage, error) {
+		if strings.Join(revisions, " ") != "--max-count=1 ace893fb2c9553a38a873fb03d0e21a406b351a1" {
+			t.Errorf("Checked the wrong commits: %v", revisions)
+		}
+		return []git.CommitMessage{{SHA: "ace893fb2c9553a38a873fb03d0e21a406b351a1", Message: "Fix the docs [CI SKIP]"}}, nil
+	}
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.ResultFile = resultPath
+		args.SkipCI = true
+	})
+	main()
+
+	if exited != exitSkipCI {
+		t.Errorf("Received the wrong exit code: %v, want %v", exited, exitSkipCI)
+	}
+	got := readResult(resultPath, t)
+	if !got.Success || got.ExitCode != exitSkipCI || got.SkipReason == "" || got.HeadSHA != "" {
+		t.Errorf("Received the wrong result: %+v", got)
+	}
+}
+
+func TestMainSkipCIPR(t *testing.T) {
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"fetch --progress origin pull/15/head:pr",
+	}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	exited := exitOK
+	osExit = func(code int) { exited = code }
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getCommitMessages = func(revisions ...string) ([]git.CommitMessage, error) {
+		// Every commit in the pull request is checked, not just its head
+		want := "1d6d2d8e5e4d2b1ac1b4e0c31f43e4a2e3b3f4b5..ace893fb2c9553a38a873fb03d0e21a406b351a1"
+		if strings.Join(revisions, " ") != want {
+			t.Errorf("Checked the wrong commits: %v, want %v", revisions, want)
+		}
+		return []git.CommitMessage{
+			{SHA: "ace893fb2c9553a38a873fb03d0e21a406b351a1", Message: "Fix the typo"},
+			{SHA: "302f5f5b48b9feee797a66c88811f1770bcb2dcf", Message: "WIP [no build]"},
+		}, nil
+	}
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.PullRequest = 15
+		args.SkipCI = true
+		args.SkipCIMarkers = []string{"[skip ci]", "[no build]"}
+	})
+	main()
+
+	if exited != exitSkipCI {
+		t.Errorf("Received the wrong exit code: %v, want %v", exited, exitSkipCI)
+	}
+	want := "Skipping CI, the message of 302f5f5b48b9feee797a66c88811f1770bcb2dcf has [no build]"
+	if result.SkipReason != want {
+		t.Errorf("Received the wrong reason: %q, want %q", result.SkipReason, want)
+	}
+}
+
+func TestMainSkipCINoMarker(t *testing.T) {
+	executeStream = mockExec([]string{
+		"clone --quiet --progress --branch master https://github.com/testOrg/testRepo.git /tmp/foo",
+		"config user.name sd-buildbot",
+		"config user.email dev-null@screwdriver.cd",
+		"reset --hard ace893fb2c9553a38a873fb03d0e21a406b351a1",
+	}, t, false)
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(0, t)
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getCommitMessages = func(...string) ([]git.CommitMessage, error) {
+		return []git.CommitMessage{{SHA: "ace893fb2c9553a38a873fb03d0e21a406b351a1", Message: "Skip the ci step in the docs"}}, nil
+	}
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.SkipCI = true
+	})
+	main()
+}
+
+func TestSkipCIPlan(t *testing.T) {
+	fmtPrint = mockPrint([]string{
+		"\n☛ Not checked by the plan: stopping with exit code 11 if a commit message has [skip ci], [ci skip]\n",
+	}, t)
+	getCommitMessages = func(...string) ([]git.CommitMessage, error) {
+		t.Errorf("Expected the plan not to read the commit messages")
+		return nil, nil
+	}
+
+	if skipCI(arguments.CommandArgs{SkipCI: true, Plan: true}, "--max-count=1", "ace893fb2c9553a38a873fb03d0e21a406b351a1") {
+		t.Errorf("Expected the plan to go on")
+	}
+}
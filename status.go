@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/github"
+)
+
+// reportStatus sets the commit status on GitHub, it does nothing unless --report-status is used
+var reportStatus = func(state, description string) {}
+
+// setupStatus points reportStatus at the GitHub API for this checkout
+func setupStatus(args arguments.CommandArgs) {
+	reportStatus = func(state, description string) {}
+	if !args.ReportStatus || args.Plan {
+		return
+	}
+
+	apiURL := args.GitHubAPIURL
+	if apiURL == "" {
+		apiURL = github.APIURL(args.Host)
+	}
+	client := github.NewClient(apiURL, args.HTTPSToken)
+
+	reportStatus = func(state, description string) {
+		ctx, cancel := untilSignal()
+		defer cancel()
+		err := client.SetStatus(ctx, args.Repo, args.SHA, github.Status{
+			State:       state,
+			TargetURL:   args.StatusURL,
+			Description: description,
+			Context:     args.StatusContext,
+		})
+		// Not being able to report the status shouldn't fail the build
+		if err != nil {
+			fmtPrint(redColor(fmt.Sprintf("%v\n", err)))
+		}
+	}
+}
+
+// failureDescription explains a failure in a few words, for the commit status
+func failureDescription(code int, message string) string {
+	switch code {
+	case exitMergeConflict:
+		return fmt.Sprintf("Merge conflict with %s", result.Branch)
+	case exitAuth:
+		return "Authentication failed"
+	case exitNetwork:
+		return "Unable to clone the repository"
+	case exitSHANotFound:
+		return "Commit not found"
+	case exitInterrupted:
+		return "Cancelled"
+	}
+	return strings.SplitN(strings.TrimSpace(message), "\n", 2)[0]
+}
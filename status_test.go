@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/git"
+	"github.com/stjohnjohnson/bookend-scm-github/github"
+)
+
+// fakeGitHub records the commit statuses it receives
+func fakeGitHub(t *testing.T) (*httptest.Server, *[]github.Status) {
+	statuses := []github.Status{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/testOrg/testRepo/statuses/ace893fb2c9553a38a873fb03d0e21a406b351a1" {
+			t.Errorf("Received the wrong path: %v", r.URL.Path)
+		}
+		var status github.Status
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &status)
+		statuses = append(statuses, status)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	return server, &statuses
+}
+
+func TestMainStatus(t *testing.T) {
+	server, statuses := fakeGitHub(t)
+	defer server.Close()
+	defer func() { reportStatus = func(string, string) {} }()
+
+	executeStream = func(...string) error { return nil }
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	osExit = mockExit(0, t)
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
+	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.PullRequest = 15
+		args.HTTPSToken = "secret"
+		args.ReportStatus = true
+		args.StatusContext = "bookend"
+		args.StatusURL = "https://cd.screwdriver.cd/builds/1"
+		args.GitHubAPIURL = server.URL
+	})
+	main()
+
+	want := []github.Status{
+		{State: "pending", Description: "Checking out master", Context: "bookend", TargetURL: "https://cd.screwdriver.cd/builds/1"},
+		{State: "success", Description: "Checked out 302f5f5b48b9feee797a66c88811f1770bcb2dcf", Context: "bookend", TargetURL: "https://cd.screwdriver.cd/builds/1"},
+	}
+	if len(*statuses) != len(want) || (*statuses)[0] != want[0] || (*statuses)[1] != want[1] {
+		t.Errorf("Received the wrong statuses: %+v, want %+v", *statuses, want)
+	}
+}
+
+func TestMainStatusMergeConflict(t *testing.T) {
+	server, statuses := fakeGitHub(t)
+	defer server.Close()
+	defer func() { reportStatus = func(string, string) {} }()
+	classifyFailure = func(error) git.Failure { return git.FailureMergeConflict }
+	defer func() { classifyFailure = git.Classify }()
+
+	executeStream = func(args ...string) error {
+		if args[0] == "merge" {
+			return &git.CommandError{}
+		}
+		return nil
+	}
+	fmtPrint = func(...interface{}) (int, error) { return 0, nil }
+	exited := []int{}
+	osExit = func(code int) { exited = append(exited, code) }
+	getGitVersion = mockVersion
+	getGitRevision = mockRevision
+	getChangedFiles = mockChangedFiles
+	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.PullRequest = 15
+		args.HTTPSToken = "secret"
+		args.ReportStatus = true
+		args.StatusContext = "bookend"
+		args.StatusURL = "https://cd.screwdriver.cd/builds/1"
+		args.GitHubAPIURL = server.URL
+	})
+	main()
+
+	if len(*statuses) < 2 || (*statuses)[1].State != "failure" || (*statuses)[1].Description != "Merge conflict with master" {
+		t.Errorf("Received the wrong statuses: %+v", *statuses)
+	}
+	if len(exited) == 0 || exited[0] != 7 {
+		t.Errorf("Received the wrong exit codes: %v", exited)
+	}
+}
+
+func TestFailureDescription(t *testing.T) {
+	result.Branch = "master"
+	tests := map[int]string{
+		exitMergeConflict: "Merge conflict with master",
+		exitAuth:          "Authentication failed",
+		exitNetwork:       "Unable to clone the repository",
+		exitSHANotFound:   "Commit not found",
+		exitInterrupted:   "Cancelled",
+		exitInternal:      "Something broke",
+	}
+	for code, want := range tests {
+		if got := failureDescription(code, "Something broke\nwith details\n"); got != want {
+			t.Errorf("Received the wrong description for %v: %v, want %v", code, got, want)
+		}
+	}
+}
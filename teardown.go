@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+)
+
+// teardown commits whatever the build produced, tags it and pushes it back
+// to the repository using the same credentials and identity as the checkout
+func teardown(args arguments.CommandArgs) {
+	if args.Plan {
+		resultFile = ""
+		startPlan(args)
+	}
+
+	clientVersion, err := getGitVersion()
+	if err != nil {
+		fail(exitGitMissing, fmt.Sprintf("Unable to get Git version: %v\n", err))
+		return
+	}
+	if err = checkGitVersion(clientVersion, args); err != nil {
+		fail(exitGitMissing, fmt.Sprintf("%v\n", err))
+		return
+	}
+
+	osChdir(args.TargetDir)
+
+	// Otherwise we keep the identity the checkout saved in .git/config
+	if args.GitName != "" || args.GitEmail != "" {
+		fmtPrint(greenColor("☛ Saving local git config\n"))
+		if args.GitName != "" {
+			executeStreamFail("config", "user.name", args.GitName)
+		}
+		if args.GitEmail != "" {
+			executeStreamFail("config", "user.email", args.GitEmail)
+		}
+	}
+
+	if args.CommitMessage != "" {
+		changed, err := hasChanges()
+		if err != nil {
+			fail(exitInternal, fmt.Sprintf("%v\n", err))
+			return
+		}
+		if changed {
+			fmtPrint(greenColor("\n☛ Committing changes\n"))
+			executeStreamFail("add", "--all")
+			executeStreamFail("commit", "--quiet", "--message", args.CommitMessage)
+		} else {
+			fmtPrint(greenColor("\n☛ Nothing to commit\n"))
+		}
+	}
+
+	refs := []string{"HEAD:refs/heads/" + args.Branch}
+	if args.Tag != "" {
+		message := args.TagMessage
+		if message == "" {
+			message = args.Tag
+		}
+		fmtPrint(greenColor(fmt.Sprintf("\n☛ Tagging %s\n", args.Tag)))
+		executeStreamFail("tag", "--annotate", "--message", message, args.Tag)
+		refs = append(refs, "refs/tags/"+args.Tag)
+	}
+
+	fmtPrint(greenColor(fmt.Sprintf("\n☛ Pushing to %s, on branch %s\n", args.ScmURL, args.Branch)))
+	executeStreamFail(append([]string{"push", "--atomic", args.CloneURL}, refs...)...)
+
+	head, err := getGitSha()
+	if err != nil {
+		fail(exitInternal, fmt.Sprintf("Unable to get current Git revision: %v\n", err))
+		return
+	}
+	result.HeadSHA = head
+
+	fmtPrint(greenColor(fmt.Sprintf("\n✓ Pushed %s\n", head)))
+	saveResult(true)
+}
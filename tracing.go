@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+	"github.com/stjohnjohnson/bookend-scm-github/tracing"
+)
+
+// traceService is how we're named in the exported trace
+const traceService = "bookend-scm-github"
+
+var timeNow = time.Now
+var traceClient = &http.Client{Timeout: 10 * time.Second}
+
+// tracer is the trace of this run, nil unless --trace-endpoint or --trace-file is used
+var tracer *tracing.Trace
+var traceStarted time.Time
+var traceArgs arguments.CommandArgs
+
+// secrets are hidden from the Git commands we record
+var secrets []string
+
+// maskCommand is how a Git command is recorded, with the token hidden
+func maskCommand(args []string) string {
+	command := strings.Join(append([]string{"git"}, args...), " ")
+	for _, secret := range secrets {
+		command = strings.Replace(command, secret, "****", -1)
+	}
+	return command
+}
+
+// startTrace starts timing the checkout for the trace, continuing the one
+// in $TRACEPARENT if whatever started us is tracing too
+func startTrace(args arguments.CommandArgs) {
+	tracer = nil
+	if args.Plan || (args.TraceEndpoint == "" && args.TraceFile == "") {
+		return
+	}
+	tracer = tracing.New(osGetEnv("TRACEPARENT"))
+	traceStarted, traceArgs = timeNow(), args
+}
+
+// traceAttributes describe the checkout on every span
+func traceAttributes() []tracing.Attribute {
+	attributes := []tracing.Attribute{
+		{Key: "bookend.repo", Value: traceArgs.ScmURL},
+		{Key: "bookend.branch", Value: traceArgs.Branch},
+		{Key: "bookend.sha", Value: traceArgs.SHA},
+	}
+	if traceArgs.PullRequest != 0 {
+		attributes = append(attributes, tracing.Attribute{Key: "bookend.pull_request", Value: traceArgs.PullRequest})
+	}
+	return attributes
+}
+
+// exportTrace turns the steps of the checkout into spans under a checkout
+// span, and sends them to the collector or saves them. Not being able to
+// export the trace doesn't fail the build.
+func exportTrace() {
+	if tracer == nil || !tracer.Sampled {
+		return
+	}
+	trace := tracer
+	tracer = nil
+
+	root := tracing.Span{
+		SpanID:     trace.NewSpanID(),
+		ParentID:   trace.ParentID,
+		Name:       "checkout",
+		Start:      traceStarted,
+		End:        timeNow(),
+		Attributes: append(traceAttributes(), tracing.Attribute{Key: "bookend.head_sha", Value: result.HeadSHA}),
+		Status:     tracing.StatusOK,
+	}
+	if !result.Success {
+		root.Status, root.Message = tracing.StatusError, result.Error
+	}
+	trace.Add(root)
+
+	for _, step := range result.Steps {
+		span := tracing.Span{
+			SpanID:     trace.NewSpanID(),
+			ParentID:   root.SpanID,
+			Name:       step.Name,
+			Start:      step.Started,
+			End:        step.Started.Add(time.Duration(step.Duration * float64(time.Second))),
+			Attributes: traceAttributes(),
+			Status:     tracing.StatusOK,
+		}
+		if len(step.Commands) > 0 {
+			span.Attributes = append(span.Attributes, tracing.Attribute{Key: "git.args", Value: step.Commands})
+		}
+		if step.Bytes > 0 {
+			span.Attributes = append(span.Attributes, tracing.Attribute{Key: "bookend.bytes_received", Value: int(step.Bytes)})
+		}
+		if step.Error != "" {
+			span.Status, span.Message = tracing.StatusError, step.Error
+		}
+		trace.Add(span)
+	}
+
+	if traceArgs.TraceFile != "" {
+		if err := trace.WriteFile(traceArgs.TraceFile, traceService, VERSION); err != nil {
+			fmtPrint(redColor(fmt.Sprintf("Unable to write trace file: %v\n", err)))
+		}
+	}
+	if traceArgs.TraceEndpoint != "" {
+		ctx, cancel := untilSignal()
+		defer cancel()
+		if err := trace.Send(ctx, traceClient, traceArgs.TraceEndpoint, traceService, VERSION); err != nil {
+			fmtPrint(redColor(fmt.Sprintf("%v\n", err)))
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Span status codes, as OTLP numbers them
+const (
+	StatusUnset = 0
+	StatusOK    = 1
+	StatusError = 2
+)
+
+// spanKindInternal is OTLP's SPAN_KIND_INTERNAL
+const spanKindInternal = 1
+
+var randRead = rand.Read
+
+var traceparentPattern = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// Attribute is a key and a string, int or []string value
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a single timed operation in the trace
+type Span struct {
+	SpanID     string
+	ParentID   string
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	Status     int
+	Message    string
+}
+
+// Trace is a set of spans that are exported together
+type Trace struct {
+	TraceID string
+	// ParentID is the span we were started from (if any), for the root span
+	ParentID string
+	// Sampled is false when whatever started us asked for it not to be recorded
+	Sampled bool
+	Spans   []Span
+}
+
+func randomID(size int) string {
+	id := make([]byte, size)
+	randRead(id)
+	return hex.EncodeToString(id)
+}
+
+// New starts a trace, continuing the one in traceparent (the W3C
+// traceparent header, usually from $TRACEPARENT) if it is valid
+func New(traceparent string) *Trace {
+	match := traceparentPattern.FindStringSubmatch(strings.TrimSpace(traceparent))
+	if match == nil || match[1] == "ff" || strings.Trim(match[2], "0") == "" || strings.Trim(match[3], "0") == "" {
+		return &Trace{TraceID: randomID(16), Sampled: true}
+	}
+	flags, _ := strconv.ParseUint(match[4], 16, 8)
+	return &Trace{TraceID: match[2], ParentID: match[3], Sampled: flags&1 == 1}
+}
+
+// NewSpanID returns a random ID for a span in the trace
+func (t *Trace) NewSpanID() string {
+	return randomID(8)
+}
+
+// Add records a finished span
+func (t *Trace) Add(span Span) {
+	t.Spans = append(t.Spans, span)
+}
+
+// otlpValue is an OTLP AnyValue
+type otlpValue struct {
+	StringValue *string    `json:"stringValue,omitempty"`
+	IntValue    *string    `json:"intValue,omitempty"`
+	ArrayValue  *otlpArray `json:"arrayValue,omitempty"`
+}
+
+type otlpArray struct {
+	Values []otlpValue `json:"values"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+func toValue(value interface{}) otlpValue {
+	switch v := value.(type) {
+	case int:
+		s := strconv.Itoa(v)
+		return otlpValue{IntValue: &s}
+	case []string:
+		array := otlpValue{ArrayValue: &otlpArray{Values: []otlpValue{}}}
+		for _, item := range v {
+			array.ArrayValue.Values = append(array.ArrayValue.Values, toValue(item))
+		}
+		return array
+	}
+	s := fmt.Sprint(value)
+	return otlpValue{StringValue: &s}
+}
+
+func toAttributes(attributes []Attribute) []otlpAttribute {
+	out := []otlpAttribute{}
+	for _, attribute := range attributes {
+		out = append(out, otlpAttribute{attribute.Key, toValue(attribute.Value)})
+	}
+	return out
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// OTLP returns the trace as an OTLP/JSON ExportTraceServiceRequest
+func (t *Trace) OTLP(service, version string) ([]byte, error) {
+	resource := otlpResourceSpans{ScopeSpans: make([]otlpScopeSpans, 1)}
+	resource.Resource.Attributes = toAttributes([]Attribute{
+		{"service.name", service},
+		{"service.version", version},
+	})
+	scope := &resource.ScopeSpans[0]
+	scope.Scope.Name, scope.Scope.Version = service, version
+
+	scope.Spans = []otlpSpan{}
+	for _, span := range t.Spans {
+		out := otlpSpan{
+			TraceID:           t.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentID,
+			Name:              span.Name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        toAttributes(span.Attributes),
+		}
+		out.Status.Code, out.Status.Message = span.Status, span.Message
+		scope.Spans = append(scope.Spans, out)
+	}
+	return json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{resource}})
+}
+
+// WriteFile stores the trace as an OTLP/JSON file, the format that the
+// OpenTelemetry collector's file exporter writes (one request per line)
+func (t *Trace) WriteFile(path, service, version string) error {
+	body, err := t.OTLP(service, version)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(body, '\n'), 0644)
+}
+
+// Send exports the trace to an OTLP/HTTP collector, endpoint is its base URL
+// (e.g. http://localhost:4318) or the full URL of its /v1/traces path. It
+// gives up when ctx is cancelled.
+func (t *Trace) Send(ctx context.Context, client *http.Client, endpoint, service, version string) error {
+	body, err := t.OTLP(service, version)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Unable to export trace: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		contents, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("Unable to export trace: %s %s", res.Status, strings.TrimSpace(string(contents)))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func mockRand(b []byte) (int, error) {
+	for i := range b {
+		b[i] = byte(i + 1)
+	}
+	return len(b), nil
+}
+
+func TestNew(t *testing.T) {
+	randRead = mockRand
+	defer func() { randRead = rand.Read }()
+
+	tests := map[string]Trace{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01": {
+			TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7", Sampled: true,
+		},
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00": {
+			TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7", Sampled: false,
+		},
+		"":        {TraceID: "0102030405060708090a0b0c0d0e0f10", Sampled: true},
+		"garbage": {TraceID: "0102030405060708090a0b0c0d0e0f10", Sampled: true},
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01": {
+			TraceID: "0102030405060708090a0b0c0d0e0f10", Sampled: true,
+		},
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01": {
+			TraceID: "0102030405060708090a0b0c0d0e0f10", Sampled: true,
+		},
+	}
+	for traceparent, want := range tests {
+		if got := New(traceparent); got.TraceID != want.TraceID || got.ParentID != want.ParentID || got.Sampled != want.Sampled {
+			t.Errorf("New(%q) = %+v, want %+v", traceparent, got, want)
+		}
+	}
+
+	if id := New("").NewSpanID(); id != "0102030405060708" {
+		t.Errorf("Received the wrong span id: %v", id)
+	}
+}
+
+func testTrace() *Trace {
+	start := time.Date(2017, 8, 1, 10, 0, 0, 0, time.UTC)
+	trace := &Trace{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7", Sampled: true}
+	trace.Add(Span{
+		SpanID:   "0102030405060708",
+		ParentID: "00f067aa0ba902b7",
+		Name:     "checkout",
+		Start:    start,
+		End:      start.Add(2 * time.Second),
+		Attributes: []Attribute{
+			{"bookend.repo", "github.com/testOrg/testRepo"},
+			{"bookend.pull_request", 15},
+			{"git.args", []string{"git config user.name sd-buildbot"}},
+		},
+		Status:  StatusError,
+		Message: "Failed command",
+	})
+	return trace
+}
+
+const wantOTLP = `{"resourceSpans":[{"resource":{"attributes":[` +
+	`{"key":"service.name","value":{"stringValue":"bookend-scm-github"}},` +
+	`{"key":"service.version","value":{"stringValue":"1.0.0"}}]},` +
+	`"scopeSpans":[{"scope":{"name":"bookend-scm-github","version":"1.0.0"},"spans":[{` +
+	`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"0102030405060708","parentSpanId":"00f067aa0ba902b7",` +
+	`"name":"checkout","kind":1,"startTimeUnixNano":"1501581600000000000","endTimeUnixNano":"1501581602000000000",` +
+	`"attributes":[{"key":"bookend.repo","value":{"stringValue":"github.com/testOrg/testRepo"}},` +
+	`{"key":"bookend.pull_request","value":{"intValue":"15"}},` +
+	`{"key":"git.args","value":{"arrayValue":{"values":[{"stringValue":"git config user.name sd-buildbot"}]}}}],` +
+	`"status":{"code":2,"message":"Failed command"}}]}]}]}`
+
+func TestOTLP(t *testing.T) {
+	got, err := testTrace().OTLP("bookend-scm-github", "1.0.0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(got) != wantOTLP {
+		t.Errorf("Received the wrong OTLP:\n%s\nwant:\n%s", got, wantOTLP)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "trace.json")
+
+	if err = testTrace().WriteFile(path, "bookend-scm-github", "1.0.0"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	bytes, _ := ioutil.ReadFile(path)
+	if string(bytes) != wantOTLP+"\n" {
+		t.Errorf("Received the wrong trace file: %s", bytes)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/traces" {
+			t.Errorf("Received the wrong request: %v %v", r.Method, r.URL.Path)
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Received the wrong content type: %v", contentType)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+	}))
+	defer server.Close()
+
+	for _, endpoint := range []string{server.URL, server.URL + "/", server.URL + "/v1/traces"} {
+		got = nil
+		if err := testTrace().Send(context.Background(), http.DefaultClient, endpoint, "bookend-scm-github", "1.0.0"); err != nil {
+			t.Errorf("Expected no error sending to %v, got %v", endpoint, err)
+		}
+		if got["resourceSpans"] == nil {
+			t.Errorf("Expected the trace to be sent to %v", endpoint)
+		}
+	}
+}
+
+func TestSendFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid trace\n"))
+	}))
+	defer server.Close()
+
+	err := testTrace().Send(context.Background(), http.DefaultClient, server.URL, "bookend-scm-github", "1.0.0")
+	want := "Unable to export trace: 400 Bad Request invalid trace"
+	if err == nil || err.Error() != want {
+		t.Errorf("Received the wrong error: %v, want %v", err, want)
+	}
+}
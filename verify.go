@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+)
+
+// verify checks that an existing checkout is at the requested commit (or,
+// for pull requests, that the commit has been merged into it)
+func verify(args arguments.CommandArgs) {
+	fmtPrint(greenColor(fmt.Sprintf("☛ Verifying %s\n", args.TargetDir)))
+	osChdir(args.TargetDir)
+
+	head, err := getGitSha()
+	if err != nil {
+		fail(exitInternal, fmt.Sprintf("Unable to get current Git revision: %v\n", err))
+		return
+	}
+	result.HeadSHA = head
+
+	want := strings.ToLower(args.SHA)
+	if args.PullRequest == 0 {
+		if head != want {
+			fail(exitVerificationFailed, fmt.Sprintf("HEAD is %s, expected %s\n", head, want))
+			return
+		}
+	} else {
+		merged, err := isAncestor(want, head)
+		if err != nil {
+			fail(exitInternal, fmt.Sprintf("%v\n", err))
+			return
+		}
+		if !merged {
+			fail(exitVerificationFailed, fmt.Sprintf("%s has not been merged into HEAD (%s)\n", want, head))
+			return
+		}
+	}
+
+	checkSignatures(args, "--max-count=1", want)
+
+	fmtPrint(greenColor(fmt.Sprintf("\n✓ Verified %s\n", head)))
+	saveResult(true)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stjohnjohnson/bookend-scm-github/arguments"
+)
+
+func TestVerify(t *testing.T) {
+	osChdir = func(string) error { return nil }
+	defer restoreMocks()
+
+	executeStream = mockExec([]string{}, t, false)
+	fmtPrint = mockPrint([]string{
+		"☛ Verifying /tmp/foo\n",
+		"\n✓ Verified 302f5f5b48b9feee797a66c88811f1770bcb2dcf\n",
+	}, t)
+	osExit = mockExit(0, t)
+	getGitSha = func() (string, error) { return "302f5f5b48b9feee797a66c88811f1770bcb2dcf", nil }
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.Command = "verify"
+		args.SHA = "302f5f5b48b9feee797a66c88811f1770bcb2dcf"
+	})
+	main()
+}
+
+func TestVerifyWrongHead(t *testing.T) {
+	osChdir = func(string) error { return nil }
+	defer restoreMocks()
+
+	executeStream = mockExec([]string{}, t, false)
+	fmtPrint = mockPrint([]string{
+		"☛ Verifying /tmp/foo\n",
+		"HEAD is ace893fb2c9553a38a873fb03d0e21a406b351a1, expected 302f5f5b48b9feee797a66c88811f1770bcb2dcf\n",
+	}, t)
+	osExit = mockExit(8, t)
+	getGitSha = func() (string, error) { return "ace893fb2c9553a38a873fb03d0e21a406b351a1", nil }
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.Command = "verify"
+		args.SHA = "302f5f5b48b9feee797a66c88811f1770bcb2dcf"
+	})
+	main()
+}
+
+func TestVerifyPR(t *testing.T) {
+	osChdir = func(string) error { return nil }
+	defer restoreMocks()
+
+	executeStream = mockExec([]string{}, t, false)
+	fmtPrint = mockPrint([]string{
+		"☛ Verifying /tmp/foo\n",
+		"\n✓ Verified ace893fb2c9553a38a873fb03d0e21a406b351a1\n",
+	}, t)
+	osExit = mockExit(0, t)
+	getGitSha = func() (string, error) { return "ace893fb2c9553a38a873fb03d0e21a406b351a1", nil }
+	isAncestor = func(ancestor, descendant string) (bool, error) {
+		if ancestor != "302f5f5b48b9feee797a66c88811f1770bcb2dcf" || descendant != "ace893fb2c9553a38a873fb03d0e21a406b351a1" {
+			t.Errorf("Received the wrong commits: %v, %v", ancestor, descendant)
+		}
+		return true, nil
+	}
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.Command = "verify"
+		args.SHA = "302f5f5b48b9feee797a66c88811f1770bcb2dcf"
+		args.PullRequest = 15
+	})
+	main()
+}
+
+func TestVerifyPRNotMerged(t *testing.T) {
+	osChdir = func(string) error { return nil }
+	defer restoreMocks()
+
+	executeStream = mockExec([]string{}, t, false)
+	fmtPrint = mockPrint([]string{
+		"☛ Verifying /tmp/foo\n",
+		"302f5f5b48b9feee797a66c88811f1770bcb2dcf has not been merged into HEAD (ace893fb2c9553a38a873fb03d0e21a406b351a1)\n",
+	}, t)
+	osExit = mockExit(8, t)
+	getGitSha = func() (string, error) { return "ace893fb2c9553a38a873fb03d0e21a406b351a1", nil }
+	isAncestor = func(ancestor, descendant string) (bool, error) { return false, nil }
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.Command = "verify"
+		args.SHA = "302f5f5b48b9feee797a66c88811f1770bcb2dcf"
+		args.PullRequest = 15
+	})
+	main()
+}
+
+func TestVerifyNoSha(t *testing.T) {
+	osChdir = func(string) error { return nil }
+	defer restoreMocks()
+
+	executeStream = mockExec([]string{}, t, false)
+	fmtPrint = mockPrint([]string{
+		"☛ Verifying /tmp/foo\n",
+		"Unable to get current Git revision: Bad Revision\n",
+	}, t)
+	osExit = mockExit(1, t)
+	getGitSha = func() (string, error) { return "", errors.New("Bad Revision") }
+	getArguments = testArgs(func(args *arguments.CommandArgs) {
+		args.Command = "verify"
+		args.SHA = "302f5f5b48b9feee797a66c88811f1770bcb2dcf"
+	})
+	main()
+}